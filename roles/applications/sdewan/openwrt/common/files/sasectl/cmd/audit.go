@@ -0,0 +1,170 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sasectl/utils"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// auditedCommands change cluster state, host routes, SCC or sasectl config,
+// and append records to the audit log.
+var auditedCommands = make(map[*cobra.Command]bool)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Browse the audit log of sasectl operations",
+	// Run: func(cmd *cobra.Command, args []string) {
+	// },
+}
+
+var auditListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List operations recorded in the audit log",
+	Long: `List commands which changed cluster state, host routes, SCC or sasectl config,
+oldest first, with the objects they changed. Commands which exited with an error
+are listed as failed, with the error in JSON output. A command without a finished
+record was interrupted, and is listed as incomplete.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		userName, err := cmd.Flags().GetString("user")
+		if err != nil {
+			utils.Fatal(err)
+		}
+		since, err := cmd.Flags().GetDuration("since")
+		if err != nil {
+			utils.Fatal(err)
+		}
+		object, err := cmd.Flags().GetString("object")
+		if err != nil {
+			utils.Fatal(err)
+		}
+		jsonOutput, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			utils.Fatal(err)
+		}
+
+		entries, err := utils.ReadAuditLog(auditLogFP())
+		if err != nil {
+			if os.IsNotExist(err) {
+				log.Println("No operation has been recorded in " + auditLogFP())
+				return
+			}
+			utils.Fatal(err)
+		}
+		entries = filterAuditEntries(entries, userName, since, object)
+
+		if jsonOutput {
+			data, err := json.MarshalIndent(entries, "", "  ")
+			if err != nil {
+				utils.Fatal(err)
+			}
+			fmt.Println(string(data))
+			return
+		}
+		printAuditEntries(entries)
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{
+		initCmd, initEdgeCmd, initPopCmd, initOverlayCmd, initPopOverlayCmd,
+		edgeRegToControllerCmd, overlayPreRegCmd, overlayRegDevCmd, overlayRegConCmd,
+		edgeDeregToControllerCmd, overlayDepreRegCmd, overlayDeregDev, overlayDeregCon,
+		connectionCreateCmd, connectionDeleteCmd, restoreCmd, resetCmd, upgradeCmd, migrateCmd,
+		routesApplyCmd, configUseContextCmd, exportKubeConfigCmd,
+	} {
+		auditedCommands[c] = true
+	}
+
+	auditListCmd.Flags().String("user", "", "Only list operations of this user")
+	auditListCmd.Flags().Duration("since", 0, "Only list operations started within this duration, e.g. 24h")
+	auditListCmd.Flags().String("object", "", "Only list operations which changed an object containing this text, e.g. a device name")
+	auditListCmd.Flags().Bool("json", false, "Print operations as JSON")
+	auditCmd.AddCommand(auditListCmd)
+	rootCmd.AddCommand(auditCmd)
+}
+
+// auditLogFP returns the audit log set in sasectl config, or the default one.
+func auditLogFP() string {
+	if sasectlConf != nil && sasectlConf.AuditLog != "" {
+		return sasectlConf.AuditLog
+	}
+	return utils.AuditLogFP
+}
+
+// startAudit records the start of cmd if it is audited. The command still runs
+// if it cannot be recorded, e.g. by a user who may not write the audit log.
+func startAudit(cmd *cobra.Command) {
+	if !auditedCommands[cmd] {
+		return
+	}
+	context := sccEndpoint
+	if context == "" && sasectlConf != nil {
+		ctx, _ := sasectlConf.GetContext(sccContext)
+		if ctx != nil {
+			context = ctx.Name
+		}
+	}
+	err := utils.StartAudit(auditLogFP(), cmd.CommandPath(), os.Args[1:], context)
+	if err != nil {
+		log.Println("Warning: " + err.Error() + ", the command is not audited.")
+	}
+}
+
+func filterAuditEntries(entries []*utils.AuditEntry, userName string, since time.Duration, object string) []*utils.AuditEntry {
+	var filtered []*utils.AuditEntry
+	for _, e := range entries {
+		if userName != "" && e.User != userName {
+			continue
+		}
+		if since > 0 && e.Time.Before(time.Now().Add(-since)) {
+			continue
+		}
+		if object != "" && !auditEntryChanged(e, object) {
+			continue
+		}
+		filtered = append(filtered, e)
+	}
+	return filtered
+}
+
+func auditEntryChanged(e *utils.AuditEntry, object string) bool {
+	for _, o := range e.Objects {
+		if strings.Contains(o, object) {
+			return true
+		}
+	}
+	return false
+}
+
+func printAuditEntries(entries []*utils.AuditEntry) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "TIME\tUSER\tCONTEXT\tCOMMAND\tRESULT\tOBJECTS")
+	for _, e := range entries {
+		userName := e.User
+		if e.RunAs != "" {
+			userName += " (" + e.RunAs + ")"
+		}
+		objects := []string{"-"}
+		if len(e.Objects) > 0 {
+			objects = e.Objects
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", e.Time.Local().Format(time.RFC3339), userName,
+			dashIfEmpty(e.Context), strings.Join(e.Args, " "), e.Result, objects[0])
+		// Further objects continue below, one per line.
+		for _, o := range objects[1:] {
+			fmt.Fprintf(w, "\t\t\t\t\t%s\n", o)
+		}
+	}
+	w.Flush()
+}
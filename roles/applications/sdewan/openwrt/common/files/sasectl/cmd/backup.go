@@ -0,0 +1,251 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package cmd
+
+import (
+	"encoding/json"
+	"log"
+	"sasectl/utils"
+	"time"
+
+	"github.com/akraino-edge-stack/icn-sdwan/central-controller/src/scc/pkg/module"
+	"github.com/spf13/cobra"
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Back up all objects of overlay controller to an archive",
+	Run: func(cmd *cobra.Command, args []string) {
+		fp, err := cmd.Flags().GetString("file")
+		if err != nil {
+			utils.Fatal(err)
+		}
+		if fp == "" {
+			fp = "sasectl-backup-" + time.Now().Format("20060102-150405") + ".tar.gz"
+		}
+
+		backupOverlayController(fp)
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Restore objects of overlay controller from an archive created by backup",
+	Long: `Restore objects of overlay controller from an archive created by backup.
+
+Certificates are restored by name, SCC issues them again with new keys. Export
+IPsec info of each edge again with "sasectl register overlay regDev" and apply it
+on the edge with "sasectl register edge toController". Connections of pops and
+devices are restored, others are set up by SCC itself.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fp, err := cmd.Flags().GetString("file")
+		if err != nil {
+			utils.Fatal(err)
+		}
+
+		waitSCCReady(cmd)
+		restoreOverlayController(fp)
+	},
+}
+
+func init() {
+	backupCmd.Flags().StringP("file", "f", "", "Backup archive to write, sasectl-backup-<time>.tar.gz if not set")
+	backupCmd.MarkFlagFilename("file")
+	restoreCmd.Flags().StringP("file", "f", "", "Backup archive to restore")
+	restoreCmd.MarkFlagRequired("file")
+	restoreCmd.MarkFlagFilename("file")
+	restoreCmd.Flags().Bool("wait-ready", false, "Wait until SCC is ready before restore")
+	restoreCmd.Flags().Duration("ready-timeout", utils.SCCReadyTimeout, "Maximum time to wait for SCC with --wait-ready")
+
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(restoreCmd)
+}
+
+func queryRawObjects(url string) ([]json.RawMessage, error) {
+	var objs []json.RawMessage
+
+	res, err := utils.CallRest("GET", url, "")
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal([]byte(res), &objs)
+	if err != nil {
+		return nil, err
+	}
+	return objs, nil
+}
+
+func backupOverlayController(fp string) {
+	serverUrl := getSCCServerUrl()
+	baseUrl := serverUrl + "/scc/v1/"
+	backup := &utils.SCCBackup{}
+	manifest := &utils.BackupManifest{
+		Version:   utils.BackupFormatVersion,
+		Created:   time.Now().UTC(),
+		SCCServer: serverUrl,
+	}
+
+	var err error
+	backup.ProviderIPRanges, err = queryRawObjects(baseUrl + "provider/" + utils.IPRangeCollection)
+	if err != nil {
+		utils.Fatal("Failed to query provider ipranges: " + err.Error())
+	}
+
+	overlays, err := queryRawObjects(baseUrl + utils.OverlayCollection)
+	if err != nil {
+		utils.Fatal("Failed to query overlays: " + err.Error())
+	}
+	for _, rawOverlay := range overlays {
+		overlay := utils.RawObjectName(rawOverlay)
+		overlayUrl := baseUrl + utils.OverlayCollection + "/" + overlay + "/"
+		ob := utils.OverlayBackup{Overlay: rawOverlay}
+		for _, item := range []struct {
+			collection string
+			objs       *[]json.RawMessage
+		}{
+			{utils.ProposalCollection, &ob.Proposals},
+			{utils.IPRangeCollection, &ob.IPRanges},
+			{utils.CertCollection, &ob.Certificates},
+			{utils.HubCollection, &ob.Hubs},
+			{utils.DeviceCollection, &ob.Devices},
+		} {
+			*item.objs, err = queryRawObjects(overlayUrl + item.collection)
+			if err != nil {
+				utils.Fatal("Failed to query " + item.collection + " of overlay " + overlay + ": " + err.Error())
+			}
+		}
+		ob.Connections = listConnections(serverUrl, overlay)
+
+		backup.Overlays = append(backup.Overlays, ob)
+		manifest.Overlays = append(manifest.Overlays, overlay)
+		log.Printf("Overlay %s: %d proposals, %d ipranges, %d certificates, %d pops, %d devices, %d connections.",
+			overlay, len(ob.Proposals), len(ob.IPRanges), len(ob.Certificates), len(ob.Hubs), len(ob.Devices), len(ob.Connections))
+	}
+
+	err = utils.WriteBackupArchive(fp, manifest, backup)
+	if err != nil {
+		utils.Fatal("Failed to write backup archive: " + err.Error())
+	}
+	log.Println("Overlay controller is backed up to " + fp)
+}
+
+// restoreOverlayController recreates objects in dependency order: provider
+// ipranges and overlays first, then objects of overlays, connections last.
+// Objects which already exist are skipped, so restore can be run again.
+func restoreOverlayController(fp string) {
+	manifest, backup, err := utils.ReadBackupArchive(fp)
+	if err != nil {
+		utils.Fatal(err)
+	}
+	log.Printf("Restore backup version %d created at %s from %s.", manifest.Version,
+		manifest.Created.Format(time.RFC3339), manifest.SCCServer)
+
+	serverUrl := getSCCServerUrl()
+	baseUrl := serverUrl + "/scc/v1/"
+	failed := 0
+
+	failed += restoreRawObjects(baseUrl+"provider/"+utils.IPRangeCollection, backup.ProviderIPRanges)
+	for _, ob := range backup.Overlays {
+		failed += restoreRawObjects(baseUrl+utils.OverlayCollection, []json.RawMessage{ob.Overlay})
+	}
+
+	for _, ob := range backup.Overlays {
+		overlay := utils.RawObjectName(ob.Overlay)
+		overlayUrl := baseUrl + utils.OverlayCollection + "/" + overlay + "/"
+		failed += restoreRawObjects(overlayUrl+utils.ProposalCollection, ob.Proposals)
+		failed += restoreRawObjects(overlayUrl+utils.IPRangeCollection, ob.IPRanges)
+
+		// SCC issues certificate data itself, key material of the backup can not
+		// be restored.
+		var certs []json.RawMessage
+		for _, raw := range ob.Certificates {
+			certObj := module.CertificateObject{Metadata: module.ObjectMetaData{Name: utils.RawObjectName(raw)}}
+			data, _ := json.Marshal(&certObj)
+			certs = append(certs, data)
+		}
+		failed += restoreRawObjects(overlayUrl+utils.CertCollection, certs)
+		if len(certs) > 0 {
+			log.Printf("Warning: certificates of overlay %s are issued again with new keys, export IPsec info of edges again and apply it on them.", overlay)
+		}
+
+		failed += restoreRawObjects(overlayUrl+utils.HubCollection, ob.Hubs)
+		failed += restoreRawObjects(overlayUrl+utils.DeviceCollection, ob.Devices)
+		failed += restoreConnections(serverUrl, overlay, ob.Connections)
+	}
+
+	if failed > 0 {
+		utils.Fatalf("%d objects failed to restore, see errors above.", failed)
+	}
+	log.Println("Overlay controller is restored from " + fp)
+}
+
+// restoreRawObjects posts objects missing in collection url, and returns the number of failures.
+func restoreRawObjects(url string, objs []json.RawMessage) int {
+	existing, err := queryRawObjects(url)
+	if err != nil {
+		log.Println("Failed to query " + url + ": " + err.Error())
+		return len(objs)
+	}
+	existed := make(map[string]bool)
+	for _, raw := range existing {
+		existed[utils.RawObjectName(raw)] = true
+	}
+
+	failed := 0
+	for _, raw := range objs {
+		name := utils.RawObjectName(raw)
+		if existed[name] {
+			log.Printf("%s already exists in %s, skipped.", name, url)
+			continue
+		}
+		_, err := utils.CallRest("POST", url, string(raw))
+		if err != nil {
+			log.Printf("Failed to restore %s in %s: %s", name, url, err.Error())
+			failed++
+			continue
+		}
+		log.Printf("Restored %s in %s.", name, url)
+	}
+	return failed
+}
+
+// restoreConnections recreates connections of hubs and devices. SCC sets up
+// connections between hubs and between devices itself, when they are created.
+func restoreConnections(serverUrl string, overlay string, cons []module.ConnectionObject) int {
+	existed := make(map[string]bool)
+	for _, c := range listConnections(serverUrl, overlay) {
+		existed[c.Metadata.Name] = true
+	}
+
+	failed := 0
+	for _, c := range cons {
+		if existed[c.Metadata.Name] {
+			log.Printf("Connection %s already exists in overlay %s, skipped.", c.Metadata.Name, overlay)
+			continue
+		}
+		hub, device, err := hubDeviceEnds(connEndOf(c.Info.End1), connEndOf(c.Info.End2))
+		if err != nil {
+			log.Printf("Connection %s of overlay %s is set up by SCC, skipped.", c.Metadata.Name, overlay)
+			continue
+		}
+		conUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection + "/" + overlay +
+			"/" + utils.HubCollection + "/" + hub.name + "/" + utils.DeviceCollection
+		hubDevObj := module.HubDeviceObject{
+			Metadata: module.ObjectMetaData{Name: hubDeviceObjectName(hub.name, device.name)},
+			Specification: module.HubDeviceObjectSpec{
+				Device:        device.name,
+				IsDelegateHub: true,
+			},
+		}
+		_, err = createControllerObject(conUrl, &hubDevObj, &module.HubDeviceObject{})
+		if err != nil {
+			log.Printf("Failed to restore connection %s of overlay %s.", c.Metadata.Name, overlay)
+			failed++
+			continue
+		}
+		log.Printf("Restored connection %s of overlay %s.", c.Metadata.Name, overlay)
+	}
+	return failed
+}
@@ -0,0 +1,213 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sasectl/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var supportBundleCmd = &cobra.Command{
+	Use:   "support-bundle",
+	Short: "Collect logs, CNF and host network state, SCC objects and config into an archive",
+	Long: `Collect diagnostics of this cluster into a timestamped archive: pod logs of
+sdewan-system, ip rules, NAT rules, network config, mwan3 and IPsec state of the
+CNF, policy routes of the host, SCC objects, sasectl config and helm values.
+Private keys, tokens, passwords and kube configs are redacted. Items which can not
+be collected are listed in errors.txt of the archive.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fp, err := cmd.Flags().GetString("output")
+		if err != nil {
+			utils.Fatal(err)
+		}
+		logTail, err := cmd.Flags().GetInt("log-tail")
+		if err != nil {
+			utils.Fatal(err)
+		}
+
+		dir := "sasectl-support-"
+		if host, err := os.Hostname(); err == nil {
+			dir += host + "-"
+		}
+		dir += time.Now().Format("20060102-150405")
+		if fp == "" {
+			fp = dir + ".tar.gz"
+		}
+		collectSupportBundle(fp, dir, logTail)
+	},
+}
+
+func init() {
+	supportBundleCmd.Flags().StringP("output", "o", "", "Archive to write, sasectl-support-<host>-<time>.tar.gz if not set")
+	supportBundleCmd.MarkFlagFilename("output")
+	supportBundleCmd.Flags().Int("log-tail", 5000, "Lines of each pod log to collect, -1 for all")
+	rootCmd.AddCommand(supportBundleCmd)
+}
+
+// bundleCollector adds items to a support bundle, remembering failures
+// instead of aborting, so that a broken cluster still yields a bundle.
+type bundleCollector struct {
+	bundle *utils.SupportBundle
+	errors []string
+}
+
+func (c *bundleCollector) add(name string, data string, err error) {
+	if err != nil {
+		c.errors = append(c.errors, name+": "+err.Error())
+		if data == "" {
+			return
+		}
+	}
+	err = c.bundle.Add(name, data)
+	if err != nil {
+		utils.Fatal("Failed to write " + name + " to support bundle: " + err.Error())
+	}
+}
+
+func (c *bundleCollector) addCmd(name string, cmdName string, args ...string) {
+	output, err := exec.Command(cmdName, args...).CombinedOutput()
+	if err != nil {
+		err = errors.New(cmdName + " " + strings.Join(args, " ") + ": " + err.Error())
+	}
+	c.add(name, string(output), err)
+}
+
+func (c *bundleCollector) addCNF(name string, args ...string) {
+	output, err := utils.CNFExec(args...)
+	c.add(name, output, err)
+}
+
+func (c *bundleCollector) addFile(name string, fp string) {
+	data, err := ioutil.ReadFile(fp)
+	c.add(name, string(data), err)
+}
+
+func (c *bundleCollector) addJSON(name string, obj interface{}) {
+	data, err := json.MarshalIndent(obj, "", "  ")
+	c.add(name, string(data), err)
+}
+
+func collectSupportBundle(fp string, dir string, logTail int) {
+	bundle, err := utils.NewSupportBundle(fp, dir)
+	if err != nil {
+		utils.Fatal("Failed to create support bundle: " + err.Error())
+	}
+	c := &bundleCollector{bundle: bundle}
+
+	log.Println("Collecting pods and logs of " + utils.NameSpaceName + "...")
+	c.addCmd("k8s/pods.txt", "kubectl", "get", "pod", "-n", utils.NameSpaceName, "-o", "wide")
+	c.addCmd("k8s/events.txt", "kubectl", "get", "event", "-n", utils.NameSpaceName, "--sort-by=.lastTimestamp")
+	pods, err := exec.Command("kubectl", "get", "pod", "-n", utils.NameSpaceName, "--no-headers",
+		"-o", "custom-columns=Name:.metadata.name").Output()
+	if err != nil {
+		c.add("k8s/logs", "", err)
+	}
+	for _, pod := range strings.Fields(string(pods)) {
+		c.addCmd("k8s/logs/"+pod+".log", "kubectl", "logs", "-n", utils.NameSpaceName, pod,
+			"--all-containers", "--prefix", "--tail", strconv.Itoa(logTail))
+	}
+
+	cnfPod, err := utils.FindPodFullname(utils.RouteGatewayPod)
+	if err != nil {
+		c.add("cnf", "", err)
+	} else if cnfPod != "" {
+		log.Println("Collecting network state of CNF...")
+		c.addCNF("cnf/ip-addr.txt", "ip", "addr")
+		c.addCNF("cnf/ip-rule.txt", "ip", "rule")
+		c.addCNF("cnf/ip-route.txt", "ip", "route", "show", "table", "all")
+		c.addCNF("cnf/iptables-nat.txt", "iptables", "-t", "nat", "-S")
+		c.addCNF("cnf/etc-config-network", "cat", "/etc/config/network")
+		c.addCNF("cnf/mwan3-status.txt", "mwan3", "status")
+		c.addCNF("cnf/ipsec-statusall.txt", "ipsec", "statusall")
+	} else {
+		c.add("cnf", "", errors.New("CNF pod is not found in namespace "+utils.NameSpaceName))
+	}
+
+	log.Println("Collecting policy routing of host...")
+	table := strconv.Itoa(configuredRouteTable())
+	c.addCmd("host/ip-rule.txt", "ip", "rule")
+	c.addCmd("host/ip-route-table-"+table+".txt", "ip", "route", "show", "table", table)
+	c.addFile("host/routes.yaml", utils.RouteIntentFP)
+
+	log.Println("Collecting sasectl config and helm values...")
+	c.addFile("config/sasectl.conf", configFP)
+	if sasectlConf != nil {
+		helmDir := filepath.Join(sasectlConf.ICNSdewanFilePath, "platform/deployment/helm")
+		c.addFile("config/sdewan_cnf-values.yaml", filepath.Join(helmDir, "sdewan_cnf/values.yaml"))
+		c.addFile("config/sdewan_cnf-cm.yaml", filepath.Join(helmDir, "sdewan_cnf/templates/cm.yaml"))
+		for _, release := range []string{sasectlConf.ICNSdewanCNFChartName, sasectlConf.ICNSdewanCtrlChartName} {
+			if release != "" {
+				c.addCmd("helm/"+release+"-values.yaml", "helm", "get", "values", release, "--all")
+			}
+		}
+	}
+
+	collectSCCObjects(c)
+
+	c.add("errors.txt", strings.Join(c.errors, "\n")+"\n", nil)
+	err = bundle.Close()
+	if err != nil {
+		utils.Fatal("Failed to write support bundle: " + err.Error())
+	}
+	if len(c.errors) > 0 {
+		log.Printf("%d items could not be collected, see errors.txt in the bundle.", len(c.errors))
+	}
+	log.Println("Support bundle is written to " + fp)
+}
+
+// collectSCCObjects dumps objects of all overlays, if SCC is reachable.
+func collectSCCObjects(c *bundleCollector) {
+	serverUrl, err := utils.GetSCCServerUrl(getSCCEndpoint())
+	if err != nil {
+		c.add("scc", "", err)
+		return
+	}
+	log.Println("Collecting SCC objects from " + serverUrl + "...")
+	baseUrl := serverUrl + "/scc/v1/"
+
+	objs, err := queryRawObjects(baseUrl + "provider/" + utils.IPRangeCollection)
+	if err != nil {
+		c.add("scc/provider-ipranges.json", "", err)
+	} else {
+		c.addJSON("scc/provider-ipranges.json", objs)
+	}
+	overlays, err := queryRawObjects(baseUrl + utils.OverlayCollection)
+	if err != nil {
+		c.add("scc/overlays.json", "", err)
+		return
+	}
+	c.addJSON("scc/overlays.json", overlays)
+	for _, rawOverlay := range overlays {
+		overlay := utils.RawObjectName(rawOverlay)
+		overlayUrl := baseUrl + utils.OverlayCollection + "/" + overlay + "/"
+		failed := false
+		for _, collection := range []string{utils.ProposalCollection, utils.IPRangeCollection,
+			utils.CertCollection, utils.HubCollection, utils.DeviceCollection} {
+			name := "scc/" + overlay + "/" + collection + ".json"
+			objs, err := queryRawObjects(overlayUrl + collection)
+			if err != nil {
+				c.add(name, "", err)
+				failed = true
+				continue
+			}
+			c.addJSON(name, objs)
+		}
+		// Connections are queried per pop and device, skip them if those failed.
+		if !failed {
+			c.addJSON("scc/"+overlay+"/"+utils.ConnectionCollection+".json", listConnections(serverUrl, overlay))
+		}
+	}
+}
@@ -0,0 +1,157 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package cmd
+
+import (
+	"errors"
+	"sasectl/utils"
+	"strings"
+
+	"github.com/akraino-edge-stack/icn-sdwan/central-controller/src/scc/pkg/module"
+	"github.com/spf13/cobra"
+)
+
+// Completion functions must not exit or print on failure, no candidates are
+// offered instead.
+
+type completionFunc func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective)
+
+// completionSetup returns SCC server url. Requests are not retried and time out
+// early, so that a tab press doesn't hang on an unreachable SCC.
+func completionSetup() (string, error) {
+	utils.SetRestPolicy(utils.CompletionRestTimeout, 0)
+	return utils.GetSCCServerUrl(getSCCEndpoint())
+}
+
+// completeSCCNames returns names of kind in overlay from cache or SCC.
+func completeSCCNames(kind string, overlay string) []string {
+	// The endpoint isn't resolved for the key, it may need a port-forward.
+	key := strings.Join([]string{sccContext, sccEndpoint, kind, overlay}, "|")
+	names, err := utils.CachedCompletion(key, func() ([]string, error) {
+		serverUrl, err := completionSetup()
+		if err != nil {
+			return nil, err
+		}
+		return querySCCNames(serverUrl, kind, overlay)
+	})
+	if err != nil {
+		return nil
+	}
+	return names
+}
+
+func querySCCNames(serverUrl string, kind string, overlay string) ([]string, error) {
+	var names []string
+	switch kind {
+	case utils.OverlayCollection:
+		overlays, err := queryOverlays(serverUrl)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range overlays {
+			names = append(names, o.Metadata.Name)
+		}
+	case utils.HubCollection:
+		hubs, err := queryHubs(serverUrl, overlay)
+		if err != nil {
+			return nil, err
+		}
+		for _, hub := range hubs {
+			names = append(names, hub.Metadata.Name)
+		}
+	case utils.DeviceCollection:
+		devs, err := queryDevs(serverUrl, overlay)
+		if err != nil {
+			return nil, err
+		}
+		for _, dev := range devs {
+			names = append(names, dev.Metadata.Name)
+		}
+	case utils.ConnectionCollection:
+		// Unlike listConnections, a failed query of an end is skipped.
+		seen := make(map[string]bool)
+		for _, end := range []string{connEndHub, connEndDevice} {
+			ends, err := querySCCNames(serverUrl, connCollection(end), overlay)
+			if err != nil {
+				continue
+			}
+			for _, name := range ends {
+				var cons []module.ConnectionObject
+				if end == connEndHub {
+					cons, err = queryConnections(serverUrl, overlay, name)
+				} else {
+					cons, err = queryDevConnections(serverUrl, overlay, name)
+				}
+				if err != nil {
+					continue
+				}
+				for _, c := range cons {
+					if !seen[c.Metadata.Name] {
+						seen[c.Metadata.Name] = true
+						names = append(names, c.Metadata.Name)
+					}
+				}
+			}
+		}
+	default:
+		return nil, errors.New("Unknown SCC collection " + kind)
+	}
+	return names, nil
+}
+
+// completeOverlayFlag returns overlay of cmd, the default overlay if not set.
+func completeOverlayFlag(cmd *cobra.Command) string {
+	overlay, _ := cmd.Flags().GetString("overlay")
+	if overlay == "" {
+		overlay = "overlay1"
+	}
+	return overlay
+}
+
+// completeSCC completes names of kind in the overlay given by --overlay.
+func completeSCC(kind string) completionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return completeSCCNames(kind, completeOverlayFlag(cmd)), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeSCCArgs completes the first argument only, with names of kind.
+func completeSCCArgs(kind string) completionFunc {
+	complete := completeSCC(kind)
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return complete(cmd, args, toComplete)
+	}
+}
+
+// completeConnEnds completes both ends of connection create as hub/<name> or
+// device/<name>.
+func completeConnEnds(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 1 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	overlay := completeOverlayFlag(cmd)
+	var ends []string
+	for _, kind := range []string{connEndHub, connEndDevice} {
+		for _, name := range completeSCCNames(connCollection(kind), overlay) {
+			ends = append(ends, kind+"/"+name)
+		}
+	}
+	return ends, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeContexts completes context names in sasectl config.
+func completeContexts(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var names []string
+	for _, ctx := range sasectlConf.Contexts {
+		names = append(names, ctx.Name)
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
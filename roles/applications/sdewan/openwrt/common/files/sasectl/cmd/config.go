@@ -0,0 +1,64 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sasectl/utils"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage SCC contexts of sasectl",
+	// Run: func(cmd *cobra.Command, args []string) {
+	// },
+}
+
+var configGetContextsCmd = &cobra.Command{
+	Use:   "get-contexts",
+	Short: "List SCC contexts in sasectl config",
+	Run: func(cmd *cobra.Command, args []string) {
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "CURRENT\tNAME\tENDPOINT\tTLS\tAUTH")
+		for _, ctx := range sasectlConf.Contexts {
+			current := ""
+			if ctx.Name == sasectlConf.CurrentContext {
+				current = "*"
+			}
+			auth := "none"
+			if ctx.TokenFile != "" {
+				auth = "token"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", current, ctx.Name, ctx.SCCEndpoint, ctx.TLSMode(), auth)
+		}
+		w.Flush()
+	},
+}
+
+var configUseContextCmd = &cobra.Command{
+	Use:   "use-context <name>",
+	Short: "Set the current SCC context in sasectl config",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, err := sasectlConf.GetContext(args[0])
+		if err != nil {
+			utils.Fatal(err)
+		}
+		utils.SetCurrentContext(configFP, args[0], sasectlConf)
+		log.Println("Switched to context " + args[0])
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configGetContextsCmd)
+	configCmd.AddCommand(configUseContextCmd)
+	configUseContextCmd.ValidArgsFunction = completeContexts
+	rootCmd.AddCommand(configCmd)
+}
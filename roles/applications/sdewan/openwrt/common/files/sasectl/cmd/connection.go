@@ -0,0 +1,254 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sasectl/utils"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/akraino-edge-stack/icn-sdwan/central-controller/src/scc/pkg/module"
+	"github.com/spf13/cobra"
+)
+
+const (
+	connEndHub    = "hub"
+	connEndDevice = "device"
+)
+
+// connEnd is an endpoint of a connection given as "<hub|device>/<name>".
+type connEnd struct {
+	kind string
+	name string
+}
+
+var connectionCmd = &cobra.Command{
+	Use:   "connection",
+	Short: "Manage connections between pops and edges on overlay controller",
+	// Run: func(cmd *cobra.Command, args []string) {
+	// },
+}
+
+var connectionCreateCmd = &cobra.Command{
+	Use:   "create <hub|device>/<name> <hub|device>/<name>",
+	Short: "Create hub-to-device connection",
+	Long: `Create a connection between a hub and a device, in either order.
+
+Hub-to-hub and device-to-device connections can not be created or deleted, SCC
+serves them read-only. SCC connects a pop with every other pop when it is
+registered, and connects devices directly when one of them has a public IP.
+There is no proposal selection either, connections use all proposals of the
+overlay.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		overlay, err := cmd.Flags().GetString("overlay")
+		if err != nil {
+			utils.Fatal(err)
+		}
+		end1 := parseConnEnd(args[0])
+		end2 := parseConnEnd(args[1])
+
+		createConnection(overlay, end1, end2)
+	},
+}
+
+var connectionListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List connections of overlay",
+	Run: func(cmd *cobra.Command, args []string) {
+		overlay, err := cmd.Flags().GetString("overlay")
+		if err != nil {
+			utils.Fatal(err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "NAME\tEND1\tEND2\tSTATE")
+		for _, c := range listConnections(getSCCServerUrl(), overlay) {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.Metadata.Name,
+				formatConnEnd(c.Info.End1), formatConnEnd(c.Info.End2), c.Info.State)
+		}
+		w.Flush()
+	},
+}
+
+var connectionDescribeCmd = &cobra.Command{
+	Use:   "describe <name>",
+	Short: "Show details of connection",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		overlay, err := cmd.Flags().GetString("overlay")
+		if err != nil {
+			utils.Fatal(err)
+		}
+
+		c := findConnection(getSCCServerUrl(), overlay, args[0])
+		data, err := json.MarshalIndent(c, "", "  ")
+		if err != nil {
+			utils.Fatal(err)
+		}
+		fmt.Println(string(data))
+	},
+}
+
+var connectionDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete connection",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		overlay, err := cmd.Flags().GetString("overlay")
+		if err != nil {
+			utils.Fatal(err)
+		}
+
+		deleteConnection(overlay, args[0])
+	},
+}
+
+func init() {
+	connectionCmd.PersistentFlags().StringP("overlay", "o", "overlay1", "Overlay of connections")
+	connectionCmd.RegisterFlagCompletionFunc("overlay", completeSCC(utils.OverlayCollection))
+	connectionCreateCmd.ValidArgsFunction = completeConnEnds
+	connectionDescribeCmd.ValidArgsFunction = completeSCCArgs(utils.ConnectionCollection)
+	connectionDeleteCmd.ValidArgsFunction = completeSCCArgs(utils.ConnectionCollection)
+
+	connectionCmd.AddCommand(connectionCreateCmd)
+	connectionCmd.AddCommand(connectionListCmd)
+	connectionCmd.AddCommand(connectionDescribeCmd)
+	connectionCmd.AddCommand(connectionDeleteCmd)
+	rootCmd.AddCommand(connectionCmd)
+}
+
+func parseConnEnd(arg string) connEnd {
+	fields := strings.SplitN(arg, "/", 2)
+	if len(fields) != 2 || fields[1] == "" || (fields[0] != connEndHub && fields[0] != connEndDevice) {
+		utils.Fatal("Invalid connection end " + arg + ", use hub/<name> or device/<name>")
+	}
+	return connEnd{kind: fields[0], name: fields[1]}
+}
+
+// connEndOf returns the hub or device of a connection end returned by SCC.
+func connEndOf(end module.ConnectionEnd) connEnd {
+	return connEnd{kind: strings.ToLower(end.Type), name: utils.ConnectionEndName(end)}
+}
+
+func formatConnEnd(end module.ConnectionEnd) string {
+	e := connEndOf(end)
+	return e.kind + "/" + e.name
+}
+
+func connCollection(kind string) string {
+	if kind == connEndHub {
+		return utils.HubCollection
+	}
+	return utils.DeviceCollection
+}
+
+// hubDeviceEnds orders the ends of a hub-to-device connection. SCC sets up
+// hub-to-hub and device-to-device connections itself, its connection API only
+// supports GET.
+func hubDeviceEnds(end1 connEnd, end2 connEnd) (connEnd, connEnd, error) {
+	if end1.kind == end2.kind {
+		return connEnd{}, connEnd{}, errors.New("Only hub-to-device connections can be managed, " + end1.kind + "-to-" + end2.kind +
+			" connections are set up by SCC when pops and edges are registered and are read-only in SCC API")
+	}
+	if end1.kind != connEndHub {
+		end1, end2 = end2, end1
+	}
+	return end1, end2, nil
+}
+
+func createConnection(overlay string, end1 connEnd, end2 connEnd) {
+	hub, device, err := hubDeviceEnds(end1, end2)
+	if err != nil {
+		utils.Fatal(err)
+	}
+	regOverlayCon(overlay, device.name, hub.name)
+}
+
+// hubDeviceConnectionName returns the name SCC gives the connection of hub and
+// device, "<end1>-<end2>" with ends named "<Type>.<name>".
+func hubDeviceConnectionName(hub string, device string) string {
+	return "Hub." + hub + "-Device." + device
+}
+
+// listConnections collects connections of all hubs and devices of overlay,
+// sorted by name.
+func listConnections(serverUrl string, overlay string) []module.ConnectionObject {
+	var cons []module.ConnectionObject
+	seen := make(map[string]bool)
+	addCons := func(objs []module.ConnectionObject) {
+		for _, c := range objs {
+			if !seen[c.Metadata.Name] {
+				seen[c.Metadata.Name] = true
+				cons = append(cons, c)
+			}
+		}
+	}
+
+	hubs, err := queryHubs(serverUrl, overlay)
+	if err != nil {
+		utils.Fatal("Failed to query pops of overlay " + overlay)
+	}
+	for _, hub := range hubs {
+		objs, err := queryConnections(serverUrl, overlay, hub.Metadata.Name)
+		if err != nil {
+			log.Printf("Failed to query connections from pop %s.", hub.Metadata.Name)
+			continue
+		}
+		addCons(objs)
+	}
+
+	devs, err := queryDevs(serverUrl, overlay)
+	if err != nil {
+		utils.Fatal("Failed to query devices of overlay " + overlay)
+	}
+	for _, dev := range devs {
+		objs, err := queryDevConnections(serverUrl, overlay, dev.Metadata.Name)
+		if err != nil {
+			log.Printf("Failed to query connections from device %s.", dev.Metadata.Name)
+			continue
+		}
+		addCons(objs)
+	}
+
+	sort.Slice(cons, func(i, j int) bool {
+		return cons[i].Metadata.Name < cons[j].Metadata.Name
+	})
+	return cons
+}
+
+func findConnection(serverUrl string, overlay string, conName string) module.ConnectionObject {
+	for _, c := range listConnections(serverUrl, overlay) {
+		if c.Metadata.Name == conName {
+			return c
+		}
+	}
+	utils.Fatal("Connection " + conName + " not found in overlay " + overlay)
+	return module.ConnectionObject{}
+}
+
+func deleteConnection(overlay string, conName string) {
+	serverUrl := getSCCServerUrl()
+	c := findConnection(serverUrl, overlay, conName)
+	hub, device, err := hubDeviceEnds(connEndOf(c.Info.End1), connEndOf(c.Info.End2))
+	if err != nil {
+		utils.Fatal(err)
+	}
+
+	// Hub-to-device connections are owned by the hub device relation.
+	conUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection + "/" + overlay +
+		"/" + utils.HubCollection + "/" + hub.name + "/" + utils.DeviceCollection + "/" + device.name
+	err = deleteControllerObjects(conUrl)
+	if err != nil {
+		utils.Fatal("Failed to delete connection " + conName + ": " + err.Error())
+	}
+	log.Println("Connection " + conName + " is deleted.")
+}
@@ -89,7 +89,7 @@ func deregEdgeToOverlay() {
 }
 
 func deregOverlayDePreReg() {
-	serverIP := utils.CheckPodIP("scc")
+	serverUrl := getSCCServerUrl()
 	// 3 Nodes PreReg Con
 	// TODO: Provide more general way.
 	overlay := "overlay1"
@@ -99,72 +99,67 @@ func deregOverlayDePreReg() {
 	providerIPrangeName := "provideripr"
 	dataIPRangeName := "dataipr"
 
-	resetOverlayIPRule("40")
-	err := deregIPRange(serverIP, overlay, dataIPRangeName)
+	resetOverlayIPRule(configuredRouteTable())
+	deregIPRangeIfExists(serverUrl, overlay, dataIPRangeName)
+	deregIPRangeIfExists(serverUrl, "", providerIPrangeName)
+	err := deregProposal(serverUrl, overlay, overlayProposal2)
 	if err != nil {
 		log.Print(err.Error())
 	}
-	err = deregIPRange(serverIP, "", providerIPrangeName)
+	err = deregProposal(serverUrl, overlay, overlayProposal1)
 	if err != nil {
 		log.Print(err.Error())
 	}
-	err = deregProposal(serverIP, overlay, overlayProposal2)
-	if err != nil {
-		log.Print(err.Error())
-	}
-	err = deregProposal(serverIP, overlay, overlayProposal1)
-	if err != nil {
-		log.Print(err.Error())
-	}
-	err = deregOverlay(serverIP, overlay)
+	err = deregOverlay(serverUrl, overlay)
 	if err != nil {
 		log.Print(err.Error())
 	}
 }
 
 func deregOverlayDeregDev(devType string, deviceName string) error {
-	serverIP := utils.CheckPodIP("scc")
+	serverUrl := getSCCServerUrl()
 	var err error
 	if devType == "edge" {
-		err = deregDevice(serverIP, "overlay1", deviceName)
+		err = deregDevice(serverUrl, "overlay1", deviceName)
 		if err != nil {
 			log.Print(err.Error())
 			return err
 		}
-		err = deregCert(serverIP, "overlay1", deviceName)
+		err = deregCert(serverUrl, "overlay1", deviceName)
 		if err != nil {
 			log.Print(err.Error())
 			return err
 		}
 	} else if devType == "pop" || devType == "popoverlay" {
-		err = deregHub(serverIP, "overlay1", deviceName)
+		err = deregHub(serverUrl, "overlay1", deviceName)
 		if err != nil {
 			log.Print(err.Error())
 			return err
 		}
 	} else {
-		log.Fatal("Illegal device type")
+		utils.Fatal("Illegal device type")
 	}
+	regSyncPeerRules(serverUrl, "overlay1")
 	return nil
 }
 
 func deregOverlayDeregCon(overlay string, deviceName string, hubName string) error {
-	serverIP := utils.CheckPodIP("scc")
+	serverUrl := getSCCServerUrl()
 
-	deregConUrl := "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection +
+	deregConUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection +
 		"/" + overlay + "/" + utils.HubCollection + "/" + hubName + "/" + utils.DeviceCollection +
 		"/" + deviceName
 
 	err := deleteControllerObjects(deregConUrl)
 	if err != nil {
-		log.Fatal(err)
+		utils.Fatal(err)
 	}
 	return nil
 }
 
 func deregOverlayCon(overlay string, deviceName string, hubName string) error {
-	serverIP := utils.CheckPodIP("scc")
-	regConUrl := "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection +
+	serverUrl := getSCCServerUrl()
+	regConUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection +
 		"/" + overlay + "/" + utils.HubCollection + "/" + hubName + "/" + utils.DeviceCollection
 
 	// conName := hubName + strings.Replace(deviceName, "-", "", -1) + "conn"
@@ -184,8 +179,8 @@ func deregOverlayCon(overlay string, deviceName string, hubName string) error {
 	return nil
 }
 
-func deregOverlay(serverIP string, overlay string) error {
-	overlayUrl := "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection +
+func deregOverlay(serverUrl string, overlay string) error {
+	overlayUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection +
 		"/" + overlay
 
 	_, err := utils.CallRest("DELETE", overlayUrl, "")
@@ -197,8 +192,8 @@ func deregOverlay(serverIP string, overlay string) error {
 	return nil
 }
 
-func deregProposal(serverIP string, overlay string, proposal string) error {
-	proposalUrl := "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection +
+func deregProposal(serverUrl string, overlay string, proposal string) error {
+	proposalUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection +
 		"/" + overlay + "/" + utils.ProposalCollection + "/" + proposal
 
 	_, err := utils.CallRest("DELETE", proposalUrl, "")
@@ -210,8 +205,8 @@ func deregProposal(serverIP string, overlay string, proposal string) error {
 	return nil
 }
 
-func deregDevice(serverIP string, overlay string, deviceName string) error {
-	DeviceUrl := "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection +
+func deregDevice(serverUrl string, overlay string, deviceName string) error {
+	DeviceUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection +
 		"/" + overlay + "/" + utils.DeviceCollection + "/" + deviceName
 	_, err := utils.CallRest("DELETE", DeviceUrl, "")
 	if err != nil {
@@ -221,8 +216,8 @@ func deregDevice(serverIP string, overlay string, deviceName string) error {
 	return nil
 }
 
-func deregHub(serverIP string, overlay string, hubName string) error {
-	HubUrl := "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection +
+func deregHub(serverUrl string, overlay string, hubName string) error {
+	HubUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection +
 		"/" + overlay + "/" + utils.HubCollection + "/" + hubName
 
 	_, err := utils.CallRest("DELETE", HubUrl, "")
@@ -234,13 +229,32 @@ func deregHub(serverIP string, overlay string, hubName string) error {
 	return nil
 }
 
-func deregIPRange(serverIP string, overlay string, ipRangeName string) error {
+// deregIPRangeIfExists deletes IP range ipRangeName of overlay, or of provider if
+// overlay is empty, if SCC has it.
+func deregIPRangeIfExists(serverUrl string, overlay string, ipRangeName string) {
+	ipRanges, err := queryIPranges(serverUrl, overlay)
+	if err != nil {
+		log.Print(err.Error())
+		return
+	}
+	for _, ipRange := range ipRanges {
+		if ipRange.Metadata.Name != ipRangeName {
+			continue
+		}
+		err = deregIPRange(serverUrl, overlay, ipRangeName)
+		if err != nil {
+			log.Print(err.Error())
+		}
+	}
+}
+
+func deregIPRange(serverUrl string, overlay string, ipRangeName string) error {
 	var ipRangeUrl string
 	if overlay != "" {
-		ipRangeUrl = "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection +
+		ipRangeUrl = serverUrl + "/scc/v1/" + utils.OverlayCollection +
 			"/" + overlay + "/" + utils.IPRangeCollection + "/" + ipRangeName
 	} else {
-		ipRangeUrl = "http://" + serverIP + ":9015/scc/v1/provider/" + utils.IPRangeCollection +
+		ipRangeUrl = serverUrl + "/scc/v1/provider/" + utils.IPRangeCollection +
 			"/" + ipRangeName
 	}
 
@@ -253,8 +267,8 @@ func deregIPRange(serverIP string, overlay string, ipRangeName string) error {
 	return nil
 }
 
-func deregCert(serverIP string, overlay string, deviceName string) error {
-	CertUrl := "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection +
+func deregCert(serverUrl string, overlay string, deviceName string) error {
+	CertUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection +
 		"/" + overlay + "/" + utils.CertCollection
 
 	err := deleteControllerObjects(CertUrl)
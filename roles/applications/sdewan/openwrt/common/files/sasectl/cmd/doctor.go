@@ -0,0 +1,304 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sasectl/utils"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check prerequisites of init and report how to fix failures",
+	Long: `Check tools, deployment files, Multus, cert-manager and policy routing of this
+host, and role specific prerequisites: provider IPs not in use, public IP and kube
+config for edge and pop, SCC manifests for overlay. The role defaults to the
+initialized role in sasectl config. Addresses are given with the flags of init,
+an initialized cluster is checked with the ones in its CNF values. Exits with 1
+if a check fails.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		role, err := cmd.Flags().GetString("role")
+		if err != nil {
+			utils.Fatal(err)
+		}
+		providerIP, err := cmd.Flags().GetString("providerIP")
+		if err != nil {
+			utils.Fatal(err)
+		}
+		publicIP, err := cmd.Flags().GetString("publicIP")
+		if err != nil {
+			utils.Fatal(err)
+		}
+		popProviderIP, err := cmd.Flags().GetString("popProviderIP")
+		if err != nil {
+			utils.Fatal(err)
+		}
+
+		results := runDoctor(role, providerIP, publicIP, popProviderIP)
+		if printDoctorResults(results) {
+			utils.Exit(1, "Some checks failed")
+		}
+	},
+}
+
+func init() {
+	doctorCmd.Flags().StringP("role", "r", "", "Role to check for: edge, pop, overlay or popoverlay")
+	doctorCmd.RegisterFlagCompletionFunc("role", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"edge", "pop", "overlay", "popoverlay"}, cobra.ShellCompDirectiveNoFileComp
+	})
+	doctorCmd.Flags().String("providerIP", "", "Provider IP of CNF, as given to init")
+	doctorCmd.Flags().String("publicIP", "", "Public IP of edge CNF, as given to init edge")
+	doctorCmd.Flags().String("popProviderIP", "", "Provider IP pop tunnels terminate on, as given to init popoverlay")
+	rootCmd.AddCommand(doctorCmd)
+}
+
+type doctorResult struct {
+	check string
+	err   error
+	hint  string
+}
+
+type doctor struct {
+	results []doctorResult
+}
+
+func (d *doctor) check(check string, hint string, err error) {
+	d.results = append(d.results, doctorResult{check: check, err: err, hint: hint})
+}
+
+func runDoctor(role string, providerIP string, publicIP string, popProviderIP string) []doctorResult {
+	d := &doctor{}
+	initialized := sasectlConf.ICNSdewanRole
+	if role == "" {
+		role = initialized
+	}
+
+	for _, tool := range []string{"kubectl", "helm", "sudo", "ip"} {
+		_, err := exec.LookPath(tool)
+		d.check("tool "+tool, "Install "+tool+" and make sure it is in PATH.", err)
+	}
+	d.check("kubernetes API", "Check that the cluster is up and kubectl is configured, e.g. KUBECONFIG.",
+		kubectlCheck("get", "namespace", "kube-system"))
+
+	doctorFiles(d)
+
+	d.check("multus", "Install Multus CNI, the network-attachment-definitions CRD is missing.",
+		kubectlCheck("get", "crd", "network-attachment-definitions.k8s.cni.cncf.io"))
+	d.check("ovn4nfv", "Install ovn4nfv-k8s-plugin, the provider network CRDs are missing.",
+		kubectlCheck("get", "crd", "providernetworks.k8s.plugin.opnfv.org", "networks.k8s.plugin.opnfv.org"))
+	err := kubectlCheck("get", "crd", "certificates.cert-manager.io", "issuers.cert-manager.io")
+	if err == nil {
+		err = kubectlCheck("wait", "--for=condition=Available", "deployment", "--all", "-n", "cert-manager", "--timeout=10s")
+	}
+	d.check("cert-manager", "Install cert-manager in namespace cert-manager and wait until its deployments are available.", err)
+
+	if sasectlConf.RouteTable != 0 {
+		d.check(fmt.Sprintf("route table %d", sasectlConf.RouteTable),
+			"Set Route-Table in "+configFP+" to a free table, or remove it to allocate one.",
+			utils.CheckRouteTable(sasectlConf.RouteTable))
+	} else {
+		_, err := utils.AllocRouteTable()
+		d.check("free route table", "Run sasectl as root, or free a routing table.", err)
+	}
+
+	switch role {
+	case "":
+		log.Println("No role given or initialized, role specific checks are skipped. Use --role.")
+	case "edge", "pop", "overlay", "popoverlay":
+		doctorRole(d, role, initialized, providerIP, publicIP, popProviderIP)
+	default:
+		d.check("role", "Use one of edge, pop, overlay or popoverlay.", errors.New("unknown role "+role))
+	}
+	return d.results
+}
+
+func doctorFiles(d *doctor) {
+	baseDir := sasectlConf.ICNSdewanFilePath
+	if baseDir == "" {
+		d.check("ICN-Sdewan-File-Path", "Set ICN-Sdewan-File-Path in "+configFP+" to the icn-sdwan checkout.",
+			errors.New("not set in "+configFP))
+		return
+	}
+	helmDir := filepath.Join(baseDir, "platform/deployment/helm")
+	hint := "Run the sdewan ansible role, which generates and packages deployment files in " + baseDir + "."
+	for _, fp := range []string{
+		filepath.Join(baseDir, "namespace.yaml"),
+		filepath.Join(baseDir, "multus-cr.yaml"),
+		filepath.Join(baseDir, "default-networks.yaml"),
+		filepath.Join(helmDir, "cert/cnf_cert.yaml"),
+		filepath.Join(helmDir, "sdewan_cnf/values.yaml"),
+		filepath.Join(helmDir, "controllers-0.1.0.tgz"),
+	} {
+		_, err := os.Stat(fp)
+		d.check("file "+fp, hint, err)
+	}
+	// init packages the CNF chart itself, the package or its source is enough.
+	cnfChart := filepath.Join(helmDir, "cnf-0.1.0.tgz")
+	_, err := os.Stat(cnfChart)
+	if err != nil {
+		_, err = os.Stat(filepath.Join(helmDir, "sdewan_cnf/Chart.yaml"))
+	}
+	d.check("file "+cnfChart, hint, err)
+}
+
+func doctorRole(d *doctor, role string, initialized string, providerIP string, publicIP string, popProviderIP string) {
+	if initialized != "" {
+		hint := "Run sasectl reset before initializing cluster with another role."
+		if initialized != role {
+			d.check("role", hint, errors.New("cluster is initialized as "+initialized))
+		} else {
+			d.check("role "+role, hint, nil)
+		}
+	}
+
+	// The CNF of an initialized cluster holds provider IPs itself.
+	if initialized != "" && providerIP == "" {
+		d.check("CNF addresses", "Fix nfn of CNF values, or reset and initialize the cluster again.", doctorCNFAddresses())
+		doctorRoleFiles(d, role)
+		return
+	}
+
+	var ips []string
+	err := checkIP(providerIP, "--providerIP")
+	d.check("provider IP", "Give the provider IP of "+role+" CNF with --providerIP.", err)
+	if err == nil {
+		var ovnIP string
+		ovnIP, err = parseOVNIP(providerIP)
+		d.check("OVN IP", "Give a provider IP which maps into the default OVN subnet.", err)
+		if err == nil {
+			ips = []string{providerIP, ovnIP}
+		}
+	}
+	if role == "popoverlay" {
+		err = checkIP(popProviderIP, "--popProviderIP")
+		d.check("pop provider IP", "Give the provider IP pop tunnels terminate on with --popProviderIP.", err)
+		if err == nil {
+			ips = append(ips, popProviderIP)
+		}
+	}
+	if role == "edge" {
+		err = checkIP(publicIP, "--publicIP")
+		d.check("public IP", "Give the public IP of edge CNF with --publicIP.", err)
+	}
+	if initialized == "" {
+		for _, ip := range ips {
+			user, err := utils.FindIPUser(ip)
+			if err == nil && user != "" {
+				err = errors.New("in use by " + user)
+			}
+			d.check("IP "+ip+" free", "Release "+ip+", or choose another provider IP.", err)
+		}
+	}
+	doctorRoleFiles(d, role)
+}
+
+// doctorRoleFiles checks the SCC manifests of overlay and the kube config
+// exported by the other roles.
+func doctorRoleFiles(d *doctor, role string) {
+	if role == "overlay" || role == "popoverlay" {
+		overlayDir := filepath.Join(sasectlConf.ICNSdewanFilePath, "central-controller/deployments/kubernetes")
+		for _, f := range []string{"scc_mongo.yaml", "scc_etcd.yaml", "scc_rsync.yaml", "scc_secret.yaml", "scc.yaml"} {
+			_, err := os.Stat(filepath.Join(overlayDir, f))
+			d.check("file "+filepath.Join(overlayDir, f), "Run the sdewan ansible role to generate SCC manifests.", err)
+		}
+	}
+	if role != "overlay" {
+		// Kube config of edge and pop is exported for registration to SCC.
+		fp, err := utils.KubeConfigPath()
+		if err == nil {
+			_, err = utils.LoadKubeConfig(fp)
+		}
+		d.check("kube config", "Make sure kube config of current user can be read, it is exported for registration.", err)
+	}
+}
+
+// doctorCNFAddresses checks the addresses in CNF values of an initialized cluster.
+func doctorCNFAddresses() error {
+	cnfValueFp := filepath.Join(sasectlConf.ICNSdewanFilePath, "platform/deployment/helm/sdewan_cnf/values.yaml")
+	data, err := ioutil.ReadFile(cnfValueFp)
+	if err != nil {
+		return err
+	}
+	// Parsed here, LoadCNFValueFile exits on a broken file.
+	var cnfValue struct {
+		Nfn []utils.ICNNfnConfig `yaml:"nfn"`
+	}
+	err = yaml.Unmarshal(data, &cnfValue)
+	if err != nil {
+		return errors.New("invalid " + cnfValueFp + ": " + err.Error())
+	}
+	nfns := cnfValue.Nfn
+	if len(nfns) == 0 {
+		return errors.New("no networks in " + cnfValueFp)
+	}
+	for _, nfn := range nfns {
+		if net.ParseIP(nfn.IPAddress) == nil {
+			return errors.New("invalid IP address " + nfn.IPAddress + " of " + nfn.Interface)
+		}
+	}
+	return nil
+}
+
+// checkIP checks that ip of flag is an IPv4 or IPv6 address.
+func checkIP(ip string, flag string) error {
+	if ip == "" {
+		return errors.New(flag + " is not set")
+	}
+	if net.ParseIP(ip) == nil {
+		return errors.New("invalid IP address " + ip)
+	}
+	return nil
+}
+
+func kubectlCheck(args ...string) error {
+	output, err := exec.Command("kubectl", args...).CombinedOutput()
+	if err != nil {
+		return errors.New(strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// printDoctorResults prints a table of checks followed by hints of failed ones,
+// and returns whether any check failed.
+func printDoctorResults(results []doctorResult) bool {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tRESULT\tDETAIL")
+	var hints []string
+	for _, r := range results {
+		if r.err == nil {
+			fmt.Fprintf(w, "%s\tpass\t\n", r.check)
+			continue
+		}
+		fmt.Fprintf(w, "%s\tFAIL\t%s\n", r.check, firstLine(r.err.Error()))
+		hints = append(hints, r.check+": "+r.hint)
+	}
+	w.Flush()
+
+	if len(hints) == 0 {
+		log.Println("All checks passed.")
+		return false
+	}
+	fmt.Println("\nHints:")
+	for _, hint := range hints {
+		fmt.Println("  " + hint)
+	}
+	return true
+}
+
+func firstLine(s string) string {
+	return strings.SplitN(s, "\n", 2)[0]
+}
@@ -0,0 +1,261 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package cmd
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sasectl/utils"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/akraino-edge-stack/icn-sdwan/central-controller/src/scc/pkg/module"
+	"github.com/akraino-edge-stack/icn-sdwan/central-controller/src/scc/pkg/resource"
+	"github.com/spf13/cobra"
+)
+
+// Settings of IPsec hosts SCC deploys to devices, see SetupConnection of the
+// overlay object manager of SCC.
+const (
+	sccIpsecConnPrefix     = "Conn"
+	sccIpsecPolicyMode     = "policy-based"
+	sccIpsecPubkeyAuth     = "pubkey"
+	sccIpsecForceProposal  = "0"
+	sccIpsecConnType       = "tunnel"
+	sccIpsecStartMode      = "start"
+	sccIpsecMark           = "30"
+	sccIpsecUpdown         = "/etc/updown"
+	sccIpsecOverlayUpdown  = "/etc/updown_oip"
+	sccIpsecSourceByConfig = "%config"
+	sccIpsecWildcardSubnet = "0.0.0.0/0"
+)
+
+var driftCmd = &cobra.Command{
+	Use:   "drift",
+	Short: "Compare proposals and IPsec hosts expected by SCC with CRs on edges",
+	Run: func(cmd *cobra.Command, args []string) {
+		overlay, err := cmd.Flags().GetString("overlay")
+		if err != nil {
+			utils.Fatal(err)
+		}
+		device, err := cmd.Flags().GetString("device")
+		if err != nil {
+			utils.Fatal(err)
+		}
+
+		items := checkDrift(getSCCServerUrl(), overlay, device)
+		if len(items) == 0 {
+			log.Println("No drift found.")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "DEVICE\tKIND\tNAME\tDRIFT\tDETAIL")
+		for _, item := range items {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", item.Device, item.Kind, item.Name, item.Drift, item.Detail)
+		}
+		w.Flush()
+		utils.Exit(1, "Drift found")
+	},
+}
+
+func init() {
+	driftCmd.Flags().StringP("overlay", "o", "overlay1", "Overlay of devices")
+	driftCmd.RegisterFlagCompletionFunc("overlay", completeSCC(utils.OverlayCollection))
+	driftCmd.Flags().StringP("device", "d", "", "Only check this device, all devices of overlay if not set")
+	driftCmd.RegisterFlagCompletionFunc("device", completeSCC(utils.DeviceCollection))
+	rootCmd.AddCommand(driftCmd)
+}
+
+// checkDrift compares, for every device of overlay, the CRs SCC deploys with
+// CRs found on the edge through the kube config stored in SCC.
+func checkDrift(serverUrl string, overlay string, deviceFilter string) []utils.DriftItem {
+	devs, err := queryDevs(serverUrl, overlay)
+	if err != nil {
+		utils.Fatal("Failed to query devices of overlay " + overlay)
+	}
+	proposals, err := queryProposals(serverUrl, overlay)
+	if err != nil {
+		utils.Fatal("Failed to query proposals of overlay " + overlay)
+	}
+	cons := listConnections(serverUrl, overlay)
+	overlayIP := controllerRemote(regLocalProviderIPs())
+
+	var items []utils.DriftItem
+	found := false
+	for _, dev := range devs {
+		if deviceFilter != "" && dev.Metadata.Name != deviceFilter {
+			continue
+		}
+		found = true
+		items = append(items, checkDeviceDrift(dev, proposals, cons, overlayIP)...)
+	}
+	if deviceFilter != "" && !found {
+		utils.Fatal("Device " + deviceFilter + " not found in overlay " + overlay)
+	}
+	return items
+}
+
+func checkDeviceDrift(dev module.DeviceObject, proposals []module.ProposalObject,
+	cons []module.ConnectionObject, overlayIP string) []utils.DriftItem {
+	deviceName := dev.Metadata.Name
+	kubeConfigFp, err := writeDeviceKubeConfig(dev)
+	if err != nil {
+		log.Printf("Skip device %s: %s", deviceName, err.Error())
+		return nil
+	}
+	defer os.Remove(kubeConfigFp)
+
+	// Proposals: every proposal of overlay is deployed to device.
+	var proposalNames []string
+	var expectedManifest strings.Builder
+	for _, p := range proposals {
+		proposalNames = append(proposalNames, p.Metadata.Name)
+		proposalRes := resource.ProposalResource{
+			Name:       p.Metadata.Name,
+			Encryption: p.Specification.Encryption,
+			Hash:       p.Specification.Hash,
+			DhGroup:    p.Specification.DhGroup,
+		}
+		expectedManifest.WriteString(proposalRes.ToYaml(deviceName) + "\n---\n")
+	}
+	expectedProposals, err := utils.ParseK8sResources(expectedManifest.String(), "IpsecProposal")
+	if err != nil {
+		utils.Fatal(err)
+	}
+	actualProposals, err := utils.GetK8sResources(kubeConfigFp, IpsecProposals)
+	if err != nil {
+		log.Printf("Skip device %s: %s", deviceName, err.Error())
+		return nil
+	}
+	items := utils.CompareK8sResources(deviceName, "IpsecProposal", expectedProposals, actualProposals,
+		func(r *utils.K8sResource) string { return r.Metadata.Name })
+
+	expectedHosts, err := utils.ParseK8sResources(
+		expectedIpsecHostManifest(deviceName, cons, proposalNames, overlayIP), "IpsecHost")
+	if err != nil {
+		utils.Fatal(err)
+	}
+	actualHosts, err := utils.GetK8sResources(kubeConfigFp, IpsecHosts)
+	if err != nil {
+		log.Printf("Skip IPsec hosts of device %s: %s", deviceName, err.Error())
+		return items
+	}
+	items = append(items, utils.CompareK8sResources(deviceName, "IpsecHost", expectedHosts, actualHosts,
+		func(r *utils.K8sResource) string { return r.Metadata.Name })...)
+	return items
+}
+
+// expectedIpsecHostManifest returns the IPsec hosts expected on deviceName, one
+// to overlay controller applied by "register edge toController", and one per
+// connection of device. They are matched with CRs by resource name.
+func expectedIpsecHostManifest(deviceName string, cons []module.ConnectionObject, proposals []string,
+	overlayIP string) string {
+	var manifest strings.Builder
+	controllerRes := edgeControllerIpsecHost(deviceName, overlayIP, proposals)
+	manifest.WriteString(controllerRes.ToYaml(deviceName) + "\n---\n")
+	for _, c := range cons {
+		ipsecRes, ok := expectedIpsecHost(c, deviceName, proposals)
+		if !ok {
+			continue
+		}
+		manifest.WriteString(ipsecRes.ToYaml(deviceName) + "\n---\n")
+	}
+	return manifest.String()
+}
+
+// expectedIpsecHost returns the IPsec host SCC deploys to deviceName for
+// connection c, if the device is one of its ends. Certificates are issued by
+// SCC and not compared.
+func expectedIpsecHost(c module.ConnectionObject, deviceName string, proposals []string) (resource.IpsecResource, bool) {
+	self, peer := c.Info.End1, c.Info.End2
+	if connEndOf(peer) == (connEnd{kind: connEndDevice, name: deviceName}) {
+		self, peer = peer, self
+	}
+	if connEndOf(self) != (connEnd{kind: connEndDevice, name: deviceName}) {
+		return resource.IpsecResource{}, false
+	}
+	selfEnd, peerEnd := connEndOf(self), connEndOf(peer)
+
+	// SCC deploys all proposals of overlay to every connection. The remote is
+	// the overlay IP of the peer, which SCC reports as IP of the connection end.
+	ipsecRes := resource.IpsecResource{
+		Name:                 sccResourceName(selfEnd.name, peerEnd.name),
+		Type:                 sccIpsecPolicyMode,
+		Remote:               peer.IP,
+		AuthenticationMethod: sccIpsecPubkeyAuth,
+		LocalIdentifier:      "CN=" + sccCertName(selfEnd),
+		RemoteIdentifier:     "CN=" + sccCertName(peerEnd),
+		CryptoProposal:       proposals,
+		ForceCryptoProposal:  sccIpsecForceProposal,
+	}
+	if peerEnd.kind == connEndHub {
+		ipsecRes.Connections = resource.Connection{
+			Name:           sccIpsecConnPrefix + sccResourceName(peerEnd.name, "") + "_" + strings.Replace(peer.IP, ".", "", -1),
+			ConnectionType: sccIpsecConnType,
+			Mode:           sccIpsecStartMode,
+			LocalUpDown:    sccIpsecOverlayUpdown,
+			LocalSourceIp:  sccIpsecSourceByConfig,
+			RemoteSubnet:   sccIpsecWildcardSubnet,
+			CryptoProposal: proposals,
+		}
+	} else {
+		// Both devices share the connection named after end1 and end2.
+		ipsecRes.Connections = resource.Connection{
+			Name:           sccIpsecConnPrefix + sccResourceName(connEndOf(c.Info.End1).name, connEndOf(c.Info.End2).name),
+			ConnectionType: sccIpsecConnType,
+			Mode:           sccIpsecStartMode,
+			Mark:           sccIpsecMark,
+			LocalUpDown:    sccIpsecUpdown,
+			CryptoProposal: proposals,
+		}
+	}
+	return ipsecRes, true
+}
+
+// controllerRemote returns the address of overlay controller expected as remote
+// of IPsec hosts to it. A dual-stack CNF is reached over the family of the edge
+// public IP, which SCC does not keep, so the remote is not compared then.
+func controllerRemote(providerIPs []string) string {
+	if len(providerIPs) != 1 {
+		return ""
+	}
+	return providerIPs[0]
+}
+
+// sccResourceName returns the name SCC gives resources of name1 to name2.
+func sccResourceName(name1 string, name2 string) string {
+	return strings.ToLower(strings.Replace(name1, "-", "", -1) + strings.Replace(name2, "-", "", -1))
+}
+
+// sccCertName returns the name of the certificate SCC issues to a hub or device.
+func sccCertName(end connEnd) string {
+	return end.kind + "-" + end.name + "-cert"
+}
+
+// writeDeviceKubeConfig writes the kube config stored in SCC for dev to a temporary file.
+func writeDeviceKubeConfig(dev module.DeviceObject) (string, error) {
+	if dev.Specification.KubeConfig == "" {
+		return "", errors.New("no kube config stored in SCC")
+	}
+	data, err := base64.StdEncoding.DecodeString(dev.Specification.KubeConfig)
+	if err != nil {
+		return "", errors.New("failed to decode kube config: " + err.Error())
+	}
+	f, err := ioutil.TempFile("", "sasectl-"+dev.Metadata.Name+"-*.kubeconfig")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	_, err = f.Write(data)
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
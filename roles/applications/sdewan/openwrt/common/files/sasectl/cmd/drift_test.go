@@ -0,0 +1,135 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package cmd
+
+import (
+	"encoding/json"
+	"sasectl/utils"
+	"testing"
+
+	"github.com/akraino-edge-stack/icn-sdwan/central-controller/src/scc/pkg/module"
+)
+
+func TestExpectedIpsecHostManifest(t *testing.T) {
+	var cons []module.ConnectionObject
+	err := json.Unmarshal([]byte(topologyConnectionsJSON), &cons)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proposals := []string{"proposal1", "proposal2"}
+
+	tests := []struct {
+		name      string
+		overlayIP string
+		actual    string
+		want      []utils.DriftItem
+	}{
+		{
+			name:      "in sync",
+			overlayIP: "10.10.70.49",
+			actual: `kind: IpsecHost
+metadata:
+  name: localtoedge1
+  namespace: default
+spec:
+  type: ""
+  remote: 10.10.70.49
+  authentication_method: pubkey
+  force_crypto_proposal: "0"
+  crypto_proposal: [proposal2, proposal1]
+  connections:
+  - name: Connedge1
+    conn_type: tunnel
+    mode: start
+    mark: ""
+    local_updown: /usr/lib/ipsec/_updown iptables
+    local_sourceip: '%config'
+    crypto_proposal: [proposal1, proposal2]
+  local_identifier: CN=device-edge-1-cert
+  remote_identifier: CN=sdewan-controller-base
+  local_public_cert: cert
+---
+kind: IpsecHost
+metadata:
+  name: edge1pop1
+  namespace: default
+spec:
+  type: policy-based
+  remote: 10.10.70.39
+  authentication_method: pubkey
+  force_crypto_proposal: 0
+  crypto_proposal: [proposal1, proposal2]
+  connections:
+  - name: Connpop1_10107039
+    conn_type: tunnel
+    mode: start
+    local_updown: /etc/updown_oip
+    local_sourceip: '%config'
+    remote_subnet: 0.0.0.0/0
+    crypto_proposal: [proposal1, proposal2]
+  local_identifier: CN=device-edge-1-cert
+  remote_identifier: CN=hub-pop1-cert
+`,
+		},
+		{
+			name:      "dual-stack controller",
+			overlayIP: controllerRemote([]string{"10.10.70.49", "fd00::49"}),
+			actual: `kind: IpsecHost
+metadata:
+  name: localtoedge1
+  namespace: default
+spec:
+  remote: fd00::49
+  authentication_method: pubkey
+  force_crypto_proposal: "0"
+  crypto_proposal: [proposal1, proposal2]
+  connections:
+  - name: Connedge1
+    conn_type: tunnel
+    mode: start
+    local_updown: /usr/lib/ipsec/_updown iptables
+    local_sourceip: '%config'
+    crypto_proposal: [proposal1, proposal2]
+  local_identifier: CN=device-edge-1-cert
+  remote_identifier: CN=sdewan-controller-base
+`,
+			want: []utils.DriftItem{
+				{Device: "edge-1", Kind: "IpsecHost", Name: "edge1pop1", Drift: utils.DriftMissing},
+			},
+		},
+		{
+			name:      "controller host missing",
+			overlayIP: "10.10.70.49",
+			want: []utils.DriftItem{
+				{Device: "edge-1", Kind: "IpsecHost", Name: "edge1pop1", Drift: utils.DriftMissing},
+				{Device: "edge-1", Kind: "IpsecHost", Name: "localtoedge1", Drift: utils.DriftMissing},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected, err := utils.ParseK8sResources(
+				expectedIpsecHostManifest("edge-1", cons, proposals, tt.overlayIP), "IpsecHost")
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual, err := utils.ParseK8sResources(tt.actual, "IpsecHost")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := utils.CompareK8sResources("edge-1", "IpsecHost", expected, actual,
+				func(r *utils.K8sResource) string { return r.Metadata.Name })
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("got %+v, want %+v", got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
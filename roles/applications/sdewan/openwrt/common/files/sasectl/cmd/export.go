@@ -0,0 +1,143 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package cmd
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sasectl/utils"
+
+	"github.com/spf13/cobra"
+)
+
+// kubeConfigExportOptions select what exportKubeConfig puts into the exported file.
+type kubeConfigExportOptions struct {
+	kubeContext    string
+	server         string
+	serviceAccount string
+}
+
+var (
+	initExportOpts kubeConfigExportOptions
+	exportOpts     kubeConfigExportOptions
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export files for registration on overlay controller",
+	// Run: func(cmd *cobra.Command, args []string) {
+	// },
+}
+
+var exportKubeConfigCmd = &cobra.Command{
+	Use:   "kubeconfig",
+	Short: "Export kube config of this cluster for registration on overlay controller",
+	Run: func(cmd *cobra.Command, args []string) {
+		role := sasectlConf.ICNSdewanRole
+		if role == "" || role == "overlay" {
+			utils.Fatal("Only edge, pop or popoverlay cluster can be exported for registration")
+		}
+
+		publicIP, err := cmd.Flags().GetString("public-ip")
+		if err != nil {
+			utils.Fatal(err)
+		}
+		if publicIP == "" {
+			cnfValueFp := filepath.Join(sasectlConf.ICNSdewanFilePath, "platform/deployment/helm/sdewan_cnf/values.yaml")
+			cnfValue := utils.LoadCNFValueFile(cnfValueFp)
+			// A popoverlay cluster is registered as pop by its pop address.
+			if role == "popoverlay" {
+				publicIP, _ = cnfValue["popPublicIpAddress"].(string)
+			} else {
+				publicIP, _ = cnfValue["publicIpAddress"].(string)
+			}
+		}
+
+		exportKubeConfig(&exportOpts, publicIP)
+	},
+}
+
+func init() {
+	exportKubeConfigCmd.Flags().String("public-ip", "", "Public IP of this cluster, read from CNF values if not set")
+	addKubeConfigExportFlags(exportKubeConfigCmd, &exportOpts, "", false)
+
+	exportCmd.AddCommand(exportKubeConfigCmd)
+	rootCmd.AddCommand(exportCmd)
+}
+
+// addKubeConfigExportFlags adds flags of opts to cmd, persistent ones for a parent command.
+func addKubeConfigExportFlags(cmd *cobra.Command, opts *kubeConfigExportOptions, prefix string, persistent bool) {
+	flags := cmd.Flags()
+	if persistent {
+		flags = cmd.PersistentFlags()
+	}
+	flags.StringVar(&opts.kubeContext, prefix+"kube-context", "", "Kube config context to export, current context if not set")
+	flags.StringVar(&opts.server, prefix+"server", "", "API server url reachable from overlay controller, e.g. https://10.10.70.49:6443")
+	flags.StringVar(&opts.serviceAccount, prefix+"service-account", "", "Export a token of this service account in "+utils.NameSpaceName+" instead of current user credentials")
+}
+
+// exportKubeConfig exports current context of kube config, named as
+// "<api server>-<role>" for registration on overlay controller, with
+// credentials, cluster role and public IP embedded.
+func exportKubeConfig(opts *kubeConfigExportOptions, publicIP string) {
+	kubeConfigFp, err := utils.KubeConfigPath()
+	if err != nil {
+		utils.Fatal("Failed to export kube config file.")
+		utils.Fatal(err)
+	}
+	kubeConfig, err := utils.LoadKubeConfig(kubeConfigFp)
+	if err != nil {
+		utils.Fatal(err)
+	}
+	kubeConfig, err = kubeConfig.Minify(opts.kubeContext)
+	if err != nil {
+		utils.Fatal(err)
+	}
+
+	if opts.server != "" {
+		err = kubeConfig.SetServer(opts.server)
+		if err != nil {
+			utils.Fatal(err)
+		}
+	}
+	if opts.serviceAccount != "" {
+		token, err := utils.GetServiceAccountToken(utils.NameSpaceName, opts.serviceAccount)
+		if err != nil {
+			utils.Fatal(err)
+		}
+		err = kubeConfig.UseToken(opts.serviceAccount, token)
+		if err != nil {
+			utils.Fatal(err)
+		}
+	}
+	kubeConfig.SetMeta(&utils.KubeConfigMeta{
+		Role:     sasectlConf.ICNSdewanRole,
+		PublicIP: publicIP,
+	})
+
+	apiServer, err := utils.KubeServerHost(kubeConfig.Server())
+	if err != nil {
+		utils.Fatal(err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		utils.Fatal("Failed to export kube config file.")
+		utils.Fatal(err)
+	}
+	outFp := filepath.Join(cwd, apiServer+"-"+sasectlConf.ICNSdewanRole)
+
+	data, err := kubeConfig.Marshal()
+	if err != nil {
+		utils.Fatal(err)
+	}
+	err = ioutil.WriteFile(outFp, data, 0600)
+	if err != nil {
+		utils.Fatal(err)
+	}
+	utils.AuditChange("file " + outFp)
+	log.Println("Exported kube config to " + outFp)
+}
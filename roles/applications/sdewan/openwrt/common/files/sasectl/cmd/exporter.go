@@ -0,0 +1,374 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package cmd
+
+import (
+	"log"
+	"net/http"
+	"sasectl/utils"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akraino-edge-stack/icn-sdwan/central-controller/src/scc/pkg/module"
+	"github.com/spf13/cobra"
+)
+
+const exporterDefaultListen = ":9715"
+
+// connectionStates are the states SCC reports for connections, all exported for
+// each connection so that a state change does not start a new series.
+var connectionStates = []string{module.StateEnum.Created, module.StateEnum.Deployed,
+	module.StateEnum.Undeployed, module.StateEnum.Error}
+
+var exporterCmd = &cobra.Command{
+	Use:   "exporter",
+	Short: "Serve Prometheus metrics of overlay and tunnel state",
+	Long: `Serve Prometheus metrics at /metrics. SCC is queried for hubs, devices, their
+connections and certificates, and the CNF for its IPsec SAs, once per interval.
+Scrapes are answered from the last collection.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		listen, err := cmd.Flags().GetString("listen")
+		if err != nil {
+			utils.Fatal(err)
+		}
+		interval, err := cmd.Flags().GetDuration("interval")
+		if err != nil {
+			utils.Fatal(err)
+		}
+		overlay, err := cmd.Flags().GetString("overlay")
+		if err != nil {
+			utils.Fatal(err)
+		}
+		if interval <= 0 {
+			utils.Fatal("--interval must be positive")
+		}
+		runExporter(listen, interval, overlay)
+	},
+}
+
+func init() {
+	exporterCmd.Flags().String("listen", exporterDefaultListen, "Address to serve metrics on")
+	exporterCmd.Flags().Duration("interval", utils.ExporterInterval, "Interval of querying SCC and CNF")
+	exporterCmd.Flags().StringP("overlay", "o", "", "Only export this overlay, all overlays if not set")
+	exporterCmd.RegisterFlagCompletionFunc("overlay", completeSCC(utils.OverlayCollection))
+	rootCmd.AddCommand(exporterCmd)
+}
+
+// exporter keeps metrics of the last collection, and the CHILD SAs seen of
+// each tunnel to count rekeys, by tunnelKey.
+type exporter struct {
+	overlay  string
+	mu       sync.Mutex
+	metrics  []*utils.MetricFamily
+	childIDs map[string][]string
+	rekeys   map[string]int
+}
+
+// exporterMetrics are the metric families of a collection.
+type exporterMetrics struct {
+	up            *utils.MetricFamily
+	lastCollect   *utils.MetricFamily
+	registered    *utils.MetricFamily
+	deployed      *utils.MetricFamily
+	connDeployed  *utils.MetricFamily
+	connState     *utils.MetricFamily
+	certExpiry    *utils.MetricFamily
+	tunnelUp      *utils.MetricFamily
+	tunnelRxBytes *utils.MetricFamily
+	tunnelTxBytes *utils.MetricFamily
+	tunnelRekeys  *utils.MetricFamily
+	tunnelRekeyIn *utils.MetricFamily
+}
+
+func newExporterMetrics() *exporterMetrics {
+	family := func(name string, typ string, help string) *utils.MetricFamily {
+		return &utils.MetricFamily{Name: "sasectl_" + name, Type: typ, Help: help}
+	}
+	return &exporterMetrics{
+		up:            family("up", "gauge", "Whether the last collection from source scc or cnf succeeded."),
+		lastCollect:   family("last_collect_timestamp_seconds", "gauge", "Time of the last collection."),
+		registered:    family("registered", "gauge", "Hub or device registered in SCC."),
+		deployed:      family("deployed", "gauge", "Whether all connections of a hub or device are "+utils.Resource_Status_Deployed+", 0 if it has none."),
+		connDeployed:  family("connection_deployed", "gauge", "Whether a connection is "+utils.Resource_Status_Deployed+"."),
+		connState:     family("connection_state", "gauge", "SCC state of a connection, 1 for the current one of "+strings.Join(connectionStates, ", ")+"."),
+		certExpiry:    family("certificate_expiry_timestamp_seconds", "gauge", "Expiry of the certificate of a hub or device issued by SCC."),
+		tunnelUp:      family("tunnel_up", "gauge", "Whether the IKE SA of a tunnel of the CNF is established with an installed CHILD SA."),
+		tunnelRxBytes: family("tunnel_receive_bytes_total", "counter", "Bytes received by the CHILD SAs of a tunnel."),
+		tunnelTxBytes: family("tunnel_transmit_bytes_total", "counter", "Bytes sent by the CHILD SAs of a tunnel."),
+		tunnelRekeys:  family("tunnel_rekeys_total", "counter", "New CHILD SAs of a tunnel seen since the exporter started, by rekey or re-establishment."),
+		tunnelRekeyIn: family("tunnel_rekey_seconds", "gauge", "Time until the next rekey of a tunnel."),
+	}
+}
+
+func (m *exporterMetrics) families() []*utils.MetricFamily {
+	return []*utils.MetricFamily{m.up, m.lastCollect, m.registered, m.deployed, m.connDeployed, m.connState, m.certExpiry,
+		m.tunnelUp, m.tunnelRxBytes, m.tunnelTxBytes, m.tunnelRekeys, m.tunnelRekeyIn}
+}
+
+func runExporter(listen string, interval time.Duration, overlay string) {
+	e := &exporter{
+		overlay:  overlay,
+		childIDs: make(map[string][]string),
+		rekeys:   make(map[string]int),
+	}
+	go func() {
+		for {
+			e.collect()
+			time.Sleep(interval)
+		}
+	}()
+
+	http.HandleFunc("/metrics", e.serveMetrics)
+	log.Println("Serving metrics on " + listen + "/metrics")
+	utils.Fatal(http.ListenAndServe(listen, nil))
+}
+
+func (e *exporter) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	metrics := e.metrics
+	e.mu.Unlock()
+	w.Header().Set("Content-Type", utils.MetricsContentType)
+	utils.WriteMetrics(w, metrics)
+}
+
+func (e *exporter) collect() {
+	m := newExporterMetrics()
+	sas, err := e.collectTunnels()
+	if err != nil {
+		log.Println(err)
+	}
+	m.up.Add(boolMetric(err == nil), "source", "cnf")
+
+	tunnelOverlays, err := e.collectSCC(m, sas)
+	if err != nil {
+		log.Println(err)
+	}
+	m.up.Add(boolMetric(err == nil), "source", "scc")
+
+	addTunnelMetrics(m, sas, tunnelOverlays, e.rekeys)
+	m.lastCollect.Add(float64(time.Now().Unix()))
+
+	e.mu.Lock()
+	e.metrics = m.families()
+	e.mu.Unlock()
+}
+
+// collectTunnels returns SAs of the CNF, and counts CHILD SAs not seen before
+// on known tunnels as rekeys.
+func (e *exporter) collectTunnels() ([]utils.IpsecSA, error) {
+	output, err := utils.CNFExec("ipsec", "statusall")
+	if err != nil {
+		return nil, err
+	}
+	sas := utils.ParseIpsecStatus(output)
+
+	childIDs := make(map[string][]string)
+	for _, sa := range sas {
+		key := tunnelKey(sa)
+		for _, c := range sa.Children {
+			childIDs[key] = append(childIDs[key], c.ID)
+		}
+	}
+	for key, ids := range childIDs {
+		prev, seen := e.childIDs[key]
+		for _, id := range ids {
+			if seen && !containsString(prev, id) {
+				e.rekeys[key]++
+			}
+		}
+	}
+	e.childIDs = childIDs
+	return sas, nil
+}
+
+// collectSCC adds metrics of hubs, devices, connections and certificates, and
+// maps SAs to their peers. It returns the overlay of each mapped SA by index.
+func (e *exporter) collectSCC(m *exporterMetrics, sas []utils.IpsecSA) (map[int]string, error) {
+	tunnelOverlays := make(map[int]string)
+	serverUrl, err := utils.GetSCCServerUrl(getSCCEndpoint())
+	if err != nil {
+		return tunnelOverlays, err
+	}
+	overlays, err := queryOverlays(serverUrl)
+	if err != nil {
+		// The port-forward may be gone, resolve SCC again on the next collection.
+		utils.StopSCCPortForward()
+		return tunnelOverlays, err
+	}
+
+	for _, o := range overlays {
+		overlay := o.Metadata.Name
+		if e.overlay != "" && overlay != e.overlay {
+			continue
+		}
+		hubs, err := queryHubs(serverUrl, overlay)
+		if err != nil {
+			return tunnelOverlays, err
+		}
+		devs, err := queryDevs(serverUrl, overlay)
+		if err != nil {
+			return tunnelOverlays, err
+		}
+		var nodes []connEnd
+		for _, hub := range hubs {
+			nodes = append(nodes, connEnd{kind: connEndHub, name: hub.Metadata.Name})
+		}
+		for _, dev := range devs {
+			nodes = append(nodes, connEnd{kind: connEndDevice, name: dev.Metadata.Name})
+		}
+
+		cons := queryNodeConnections(serverUrl, overlay, nodes)
+		deployed := nodesDeployed(cons)
+		for _, n := range nodes {
+			m.registered.Add(1, "overlay", overlay, "kind", n.kind, "name", n.name)
+			m.deployed.Add(boolMetric(deployed[n.kind+"/"+n.name]), "overlay", overlay, "kind", n.kind, "name", n.name)
+		}
+		for _, c := range cons {
+			labels := []string{"overlay", overlay, "connection", c.Metadata.Name,
+				"end1", formatConnEnd(c.Info.End1), "end2", formatConnEnd(c.Info.End2)}
+			m.connDeployed.Add(boolMetric(c.Info.State == utils.Resource_Status_Deployed), labels...)
+			states := connectionStates
+			if !containsString(states, c.Info.State) {
+				states = append(states[:len(states):len(states)], c.Info.State)
+			}
+			for _, s := range states {
+				m.connState.Add(boolMetric(c.Info.State == s), append(labels, "state", s)...)
+			}
+		}
+
+		certs, err := queryCerts(serverUrl, overlay)
+		if err != nil {
+			return tunnelOverlays, err
+		}
+		for _, cert := range certs {
+			notAfter, err := utils.CertificateNotAfter(cert.Data.Ca)
+			if err != nil {
+				log.Println("Invalid certificate " + cert.Metadata.Name + " of overlay " + overlay + ": " + err.Error())
+				continue
+			}
+			m.certExpiry.Add(float64(notAfter.Unix()), "overlay", overlay, "name", cert.Metadata.Name)
+		}
+
+		for i := range sas {
+			if sas[i].Peer != "" {
+				continue
+			}
+			matchTunnelPeers(sas[i:i+1], hubs, devs, cons)
+			if sas[i].Peer != "" {
+				tunnelOverlays[i] = overlay
+			}
+		}
+	}
+	return tunnelOverlays, nil
+}
+
+// queryNodeConnections returns connections of hubs and devices in nodes,
+// skipping the ones failed to query, unlike listConnections.
+func queryNodeConnections(serverUrl string, overlay string, nodes []connEnd) []module.ConnectionObject {
+	var cons []module.ConnectionObject
+	seen := make(map[string]bool)
+	for _, n := range nodes {
+		var objs []module.ConnectionObject
+		var err error
+		if n.kind == connEndHub {
+			objs, err = queryConnections(serverUrl, overlay, n.name)
+		} else {
+			objs, err = queryDevConnections(serverUrl, overlay, n.name)
+		}
+		if err != nil {
+			continue
+		}
+		for _, c := range objs {
+			if !seen[c.Metadata.Name] {
+				seen[c.Metadata.Name] = true
+				cons = append(cons, c)
+			}
+		}
+	}
+	return cons
+}
+
+// nodesDeployed returns, by kind/name of hubs and devices, whether all their
+// connections are deployed. Ones without connections are not included.
+func nodesDeployed(cons []module.ConnectionObject) map[string]bool {
+	deployed := make(map[string]bool)
+	for _, c := range cons {
+		ok := c.Info.State == utils.Resource_Status_Deployed
+		for _, end := range []module.ConnectionEnd{c.Info.End1, c.Info.End2} {
+			key := formatConnEnd(end)
+			prev, seen := deployed[key]
+			deployed[key] = ok && (prev || !seen)
+		}
+	}
+	return deployed
+}
+
+// tunnelKey identifies the tunnel of an IKE SA. A hub accepts all devices on
+// one connection name, so its tunnels differ by remote address, while IKE SAs
+// replacing each other on reauthentication are of the same tunnel.
+func tunnelKey(sa utils.IpsecSA) string {
+	return sa.Name + " " + sa.Remote
+}
+
+// addTunnelMetrics adds metrics of tunnels, merging IKE SAs of the same tunnelKey.
+func addTunnelMetrics(m *exporterMetrics, sas []utils.IpsecSA, tunnelOverlays map[int]string, rekeys map[string]int) {
+	type tunnel struct {
+		labels   []string
+		up       bool
+		bytesIn  int64
+		bytesOut int64
+		rekeyIn  time.Duration
+		hasRekey bool
+	}
+	var keys []string
+	tunnels := make(map[string]*tunnel)
+	for i, sa := range sas {
+		key := tunnelKey(sa)
+		t, ok := tunnels[key]
+		if !ok {
+			kind, name := "", ""
+			if parts := strings.SplitN(sa.Peer, "/", 2); len(parts) == 2 {
+				kind, name = parts[0], parts[1]
+			}
+			t = &tunnel{labels: []string{"tunnel", sa.Name, "remote", sa.Remote, "overlay", tunnelOverlays[i], "kind", kind, "name", name}}
+			tunnels[key] = t
+			keys = append(keys, key)
+		}
+		rekeyTimes := []string{sa.Rekey}
+		for _, c := range sa.Children {
+			t.bytesIn += c.BytesIn
+			t.bytesOut += c.BytesOut
+			if c.State == "INSTALLED" && sa.State == "ESTABLISHED" {
+				t.up = true
+			}
+			rekeyTimes = append(rekeyTimes, c.Rekey)
+		}
+		for _, s := range rekeyTimes {
+			d, err := utils.ParseIpsecDuration(s)
+			if err == nil && (!t.hasRekey || d < t.rekeyIn) {
+				t.rekeyIn, t.hasRekey = d, true
+			}
+		}
+	}
+
+	for _, key := range keys {
+		t := tunnels[key]
+		m.tunnelUp.Add(boolMetric(t.up), t.labels...)
+		m.tunnelRxBytes.Add(float64(t.bytesIn), t.labels...)
+		m.tunnelTxBytes.Add(float64(t.bytesOut), t.labels...)
+		m.tunnelRekeys.Add(float64(rekeys[key]), t.labels...)
+		if t.hasRekey {
+			m.tunnelRekeyIn.Add(t.rekeyIn.Seconds(), t.labels...)
+		}
+	}
+}
+
+func boolMetric(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
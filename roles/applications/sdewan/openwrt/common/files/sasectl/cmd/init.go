@@ -5,28 +5,60 @@
 package cmd
 
 import (
-	"io"
+	"errors"
 	"log"
-	"os"
+	"net"
 	"os/exec"
 	"path/filepath"
 	"sasectl/utils"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
+var rolloutTimeout time.Duration
+
+const (
+	// defaultOVNSubnet is the subnet of ovn-network created by the sdewan ansible role.
+	defaultOVNSubnet = "172.16.70.0/24"
+	// defaultOVNSubnet6 is the IPv6 subnet of ovn-network if the role sets network_cidr6.
+	defaultOVNSubnet6 = "fd00:172:16:70::/64"
+)
+
+// initProviderIP6 is the IPv6 provider address of a dual-stack CNF.
+var initProviderIP6 string
+
 var initCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize cluster role of SASE-EK",
 	// PersistentPreRun: func(cmd *cobra.Command, args []string) {
 	// 	if sasectlConf.ICNSdewanRole != "" {
-	// 		log.Fatal("Cluster has already been initialized")
+	// 		utils.Fatal("Cluster has already been initialized")
 	// 	}
 	// },
-	// Run: func(cmd *cobra.Command, args []string) {
-	// 	fmt.Println("init called")
-	// },
+	Long: `Initialize cluster role of SASE-EK with a subcommand. With --interactive, host
+interfaces and networks are shown, role and addresses are prompted for, and the
+answers are written to a file, which --answers initializes from without prompts.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		interactive, err := cmd.Flags().GetBool("interactive")
+		if err != nil {
+			utils.Fatal(err)
+		}
+		answersFp, err := cmd.Flags().GetString("answers")
+		if err != nil {
+			utils.Fatal(err)
+		}
+		if !interactive && answersFp == "" {
+			cmd.Help()
+			return
+		}
+		if sasectlConf.ICNSdewanRole != "" {
+			utils.Fatal("Cluster has already been initialized")
+		}
+		initFromAnswers(interactive, answersFp)
+	},
 }
 
 var initEdgeCmd = &cobra.Command{
@@ -34,20 +66,20 @@ var initEdgeCmd = &cobra.Command{
 	Short: "Initialize cluster as edge cluster",
 	Run: func(cmd *cobra.Command, args []string) {
 		if sasectlConf.ICNSdewanRole != "" {
-			log.Fatal("Cluster has already been initialized")
+			utils.Fatal("Cluster has already been initialized")
 		}
-		// edgePubIP := "192.168.0.1"
-		providerIP, err := cmd.Flags().GetString("providerIP")
+		providerIP, publicIP := initProviderFlags(cmd)
+
+		exportAdmin, err := cmd.Flags().GetBool("export-admin")
 		if err != nil {
-			log.Fatal("Failed to get provider ip for edge CNF.")
+			utils.Fatal(err)
 		}
 
-		publicIP, err := cmd.Flags().GetString("publicIP")
+		ovnIP, err := parseOVNIP(providerIP)
 		if err != nil {
-			log.Fatal("Failed to get public ip for edge CNF.")
+			utils.Fatal(err)
 		}
-
-		initEdgeCluster(publicIP, providerIP)
+		initEdgeCluster(initDualStackNfn(providerNfn(providerIP, ovnIP)), publicIP, exportAdmin)
 	},
 }
 
@@ -56,10 +88,14 @@ var initPopCmd = &cobra.Command{
 	Short: "Initialize cluster as pop cluster",
 	Run: func(cmd *cobra.Command, args []string) {
 		if sasectlConf.ICNSdewanRole != "" {
-			log.Fatal("Cluster has already been initialized")
+			utils.Fatal("Cluster has already been initialized")
+		}
+		providerIP, publicIP := initProviderFlags(cmd)
+		ovnIP, err := parseOVNIP(providerIP)
+		if err != nil {
+			utils.Fatal(err)
 		}
-		popPubIP := "10.10.70.39"
-		initPopCluster(popPubIP)
+		initPopCluster(initDualStackNfn(providerNfn(providerIP, ovnIP)), publicIP)
 	},
 }
 
@@ -68,29 +104,16 @@ var initOverlayCmd = &cobra.Command{
 	Short: "Initialize cluster as overlay controller cluster",
 	Run: func(cmd *cobra.Command, args []string) {
 		if sasectlConf.ICNSdewanRole != "" {
-			log.Fatal("Cluster has already been initialized")
+			utils.Fatal("Cluster has already been initialized")
 			return
 		}
-		log.Println("Initialize cluster as Overlay")
-		overlayProviderNfn := []*utils.ICNNfnConfig{
-			{
-				DefaultGateway: false,
-				Interface:      "net2",
-				IPAddress:      "10.10.70.49",
-				Name:           "pnetwork",
-				Separate:       ",",
-				Namespace:      "sdewan-system",
-			},
-			{
-				DefaultGateway: false,
-				Interface:      "net0",
-				IPAddress:      "172.16.70.49",
-				Name:           "ovn-network",
-				Separate:       "",
-				Namespace:      "sdewan-system",
-			},
+		providerIP, publicIP := initProviderFlags(cmd)
+		ovnIP, err := parseOVNIP(providerIP)
+		if err != nil {
+			utils.Fatal(err)
 		}
-		initOverlayCluster(overlayProviderNfn, false)
+		log.Println("Initialize cluster as Overlay")
+		initOverlayCluster(initDualStackNfn(overlayProviderNfn(providerIP, "", ovnIP)), publicIP, "")
 	},
 }
 
@@ -99,60 +122,78 @@ var initPopOverlayCmd = &cobra.Command{
 	Short: "Initialize cluster as pop & overlay controller cluster",
 	Run: func(cmd *cobra.Command, args []string) {
 		if sasectlConf.ICNSdewanRole != "" {
-			log.Fatal("Cluster has already been initialized")
+			utils.Fatal("Cluster has already been initialized")
 			return
 		}
-		log.Println("Initialize cluster as pop & overlay")
-		overlayProviderNfn := []*utils.ICNNfnConfig{
-			{
-				DefaultGateway: false,
-				Interface:      "net2",
-				IPAddress:      "10.10.70.49",
-				Name:           "pnetwork",
-				Separate:       ",",
-				Namespace:      "sdewan-system",
-			},
-			{
-				DefaultGateway: false,
-				Interface:      "net3",
-				IPAddress:      "10.10.70.39",
-				Name:           "pnetwork",
-				Separate:       ",",
-				Namespace:      "sdewan-system",
-			},
-			{
-				DefaultGateway: false,
-				Interface:      "net0",
-				IPAddress:      "172.16.70.49",
-				Name:           "ovn-network",
-				Separate:       "",
-				Namespace:      "sdewan-system",
-			},
+		providerIP, publicIP := initProviderFlags(cmd)
+		popProviderIP, err := cmd.Flags().GetString("popProviderIP")
+		if err != nil {
+			utils.Fatal(err)
+		}
+		ovnIP, err := parseOVNIP(providerIP)
+		if err != nil {
+			utils.Fatal(err)
 		}
-		initOverlayCluster(overlayProviderNfn, true)
+		log.Println("Initialize cluster as pop & overlay")
+		initOverlayCluster(initDualStackNfn(overlayProviderNfn(providerIP, popProviderIP, ovnIP)), publicIP, popProviderIP)
 	},
 }
 
 func init() {
-
-	initEdgeCmd.Flags().String("providerIP", "", "IP address for edge CNF provider network.")
+	addKubeConfigExportFlags(initCmd, &initExportOpts, "export-", true)
+	initCmd.Flags().BoolP("interactive", "i", false, "Prompt for role and addresses, and write the answers to --answers, "+defaultInitAnswersFP+" if not set")
+	initCmd.Flags().String("answers", "", "Answers file of --interactive, init without prompts if --interactive is not set")
+	initCmd.MarkFlagFilename("answers", "yaml", "yml")
+	initCmd.PersistentFlags().StringVar(&initProviderIP6, "providerIP6", "", "IPv6 address of a dual-stack CNF on provider network, besides its IPv4 addresses")
+	initCmd.PersistentFlags().DurationVar(&rolloutTimeout, "wait-timeout", utils.RolloutTimeout, "Maximum time to wait for deployments and certificates to be ready")
+
+	initEdgeCmd.Flags().String("providerIP", "", "IPv4 or IPv6 address for edge CNF provider network.")
 	initEdgeCmd.MarkFlagRequired("providerIP")
 	initEdgeCmd.Flags().String("publicIP", "", "Public ip address for edge CNF.")
 	initEdgeCmd.MarkFlagRequired("publicIP")
+	initEdgeCmd.Flags().Bool("export-admin", false, "Export current user credentials instead of a token of least privilege service account "+utils.EdgeServiceAccountName)
 	initCmd.AddCommand(initEdgeCmd)
 
+	initPopCmd.Flags().String("providerIP", "", "IPv4 address for pop CNF provider network, e.g. 10.10.70.39")
+	initPopCmd.MarkFlagRequired("providerIP")
+	initPopCmd.Flags().String("publicIP", "", "Public ip address for pop CNF, the provider IP if not set")
 	initCmd.AddCommand(initPopCmd)
+
+	initOverlayCmd.Flags().String("providerIP", "", "IPv4 address for overlay controller CNF provider network, e.g. 10.10.70.49")
+	initOverlayCmd.MarkFlagRequired("providerIP")
+	initOverlayCmd.Flags().String("publicIP", "", "Public ip address for overlay controller CNF, the provider IP if not set")
 	initCmd.AddCommand(initOverlayCmd)
+
+	initPopOverlayCmd.Flags().String("providerIP", "", "IPv4 address for overlay controller CNF provider network, e.g. 10.10.70.49")
+	initPopOverlayCmd.MarkFlagRequired("providerIP")
+	initPopOverlayCmd.Flags().String("popProviderIP", "", "IPv4 address on the extra provider network pop tunnels terminate on, e.g. 10.10.70.39")
+	initPopOverlayCmd.MarkFlagRequired("popProviderIP")
+	initPopOverlayCmd.Flags().String("publicIP", "", "Public ip address for overlay controller CNF, the provider IP if not set")
 	initCmd.AddCommand(initPopOverlayCmd)
 	rootCmd.AddCommand(initCmd)
 }
 
-func initEdgeCluster(publicIP string, providerIP string) {
-	log.Println("Initialize cluster as Edge")
-
-	ovnNetIP := parseOVNIP(providerIP)
+// initProviderFlags returns the provider IP and public IP flags of an init
+// subcommand. The public IP defaults to the provider IP.
+func initProviderFlags(cmd *cobra.Command) (string, string) {
+	providerIP, err := cmd.Flags().GetString("providerIP")
+	if err != nil {
+		utils.Fatal("Failed to get provider ip for CNF.")
+	}
+	publicIP, err := cmd.Flags().GetString("publicIP")
+	if err != nil {
+		utils.Fatal("Failed to get public ip for CNF.")
+	}
+	if publicIP == "" {
+		publicIP = providerIP
+	}
+	return providerIP, publicIP
+}
 
-	edgeProviderNfn := []*utils.ICNNfnConfig{
+// providerNfn returns the networks of edge or pop CNF, with its addresses on
+// provider and OVN networks.
+func providerNfn(providerIP string, ovnIP string) []*utils.ICNNfnConfig {
+	return []*utils.ICNNfnConfig{
 		{
 			DefaultGateway: false,
 			Interface:      "net2",
@@ -164,49 +205,44 @@ func initEdgeCluster(publicIP string, providerIP string) {
 		{
 			DefaultGateway: false,
 			Interface:      "net0",
-			IPAddress:      ovnNetIP,
+			IPAddress:      ovnIP,
 			Name:           "ovn-network",
 			Separate:       "",
 			Namespace:      "sdewan-system",
 		},
 	}
-	initDataplane(edgeProviderNfn, publicIP, "edge")
+}
+
+func initEdgeCluster(nfn []*utils.ICNNfnConfig, publicIP string, exportAdmin bool) {
+	log.Println("Initialize cluster as Edge")
+	initDataplane(nfn, publicIP, "", "edge")
 	utils.SetClusterRole(configFP, "edge", sasectlConf)
-	exportKubeConfig()
+	// Overlay controller only manages sdewan CRs on edge, don't hand out admin credentials.
+	if !exportAdmin && initExportOpts.serviceAccount == "" {
+		err := utils.ApplyEdgeServiceAccount()
+		if err != nil {
+			utils.Fatal(err)
+		}
+		initExportOpts.serviceAccount = utils.EdgeServiceAccountName
+	}
+	exportKubeConfig(&initExportOpts, publicIP)
 	log.Println("Successfully set cluster role as Edge")
 }
 
-func initPopCluster(publicIP string) {
+func initPopCluster(nfn []*utils.ICNNfnConfig, publicIP string) {
 	log.Println("Initialize cluster as pop")
-	popProviderNfn := []*utils.ICNNfnConfig{
-		{
-			DefaultGateway: false,
-			Interface:      "net2",
-			IPAddress:      "10.10.70.39",
-			Name:           "pnetwork",
-			Separate:       ",",
-			Namespace:      "sdewan-system",
-		},
-		{
-			DefaultGateway: false,
-			Interface:      "net0",
-			IPAddress:      "172.16.70.39",
-			Name:           "ovn-network",
-			Separate:       "",
-			Namespace:      "sdewan-system",
-		},
-	}
-	initDataplane(popProviderNfn, publicIP, "pop")
+	initDataplane(nfn, publicIP, "", "pop")
 	utils.SetClusterRole(configFP, "pop", sasectlConf)
-	exportKubeConfig()
+	exportKubeConfig(&initExportOpts, publicIP)
 	log.Println("Successfully set cluster role as pop")
 }
 
-func initOverlayCluster(dataPlaneNfn []*utils.ICNNfnConfig, combined bool) {
-	overlayWorkingDir := filepath.Join(sasectlConf.ICNSdewanFilePath, "central-controller/deployments/kubernetes")
-
+// initOverlayCluster initializes overlay controller, combined with pop if
+// popPublicIP is set.
+func initOverlayCluster(dataPlaneNfn []*utils.ICNNfnConfig, publicIP string, popPublicIP string) {
 	log.Println("Setting up data plane")
 
+	combined := popPublicIP != ""
 	var clusterRole string
 	if combined {
 		clusterRole = "popoverlay"
@@ -214,7 +250,20 @@ func initOverlayCluster(dataPlaneNfn []*utils.ICNNfnConfig, combined bool) {
 		clusterRole = "overlay"
 	}
 
-	initDataplane(dataPlaneNfn, "10.10.70.49", clusterRole)
+	initDataplane(dataPlaneNfn, publicIP, popPublicIP, clusterRole)
+	initControlPlane()
+	utils.SetClusterRole(configFP, clusterRole, sasectlConf)
+
+	if combined {
+		exportKubeConfig(&initExportOpts, popPublicIP)
+	}
+
+	log.Println("Successfully set cluster role as " + clusterRole + ".")
+}
+
+// initControlPlane deploys SCC with its rsync, etcd and mongo services.
+func initControlPlane() {
+	overlayWorkingDir := filepath.Join(sasectlConf.ICNSdewanFilePath, "central-controller/deployments/kubernetes")
 	log.Println("Setting up control plane")
 	overlayCmdList := []utils.CmdInfo{
 		{CmdName: "kubectl", CmdArgs: []string{"apply", "-f", "scc_mongo.yaml", "-n", "sdewan-system"}, CmdDir: overlayWorkingDir},
@@ -229,28 +278,58 @@ func initOverlayCluster(dataPlaneNfn []*utils.ICNNfnConfig, combined bool) {
 		output, err := cmd.CombinedOutput()
 		log.Println(string(output))
 		if err != nil {
-			log.Fatal(err)
+			utils.Fatal(err)
 			return
 		}
+		utils.AuditCmd(item)
 	}
-	utils.SetClusterRole(configFP, clusterRole, sasectlConf)
+	log.Println("Waiting for control plane to be ready")
+	waitObjectsReady(overlayControllerObjects())
+}
 
-	if combined {
-		exportKubeConfig()
+// overlayProviderNfn returns the networks of CNF on overlay controller, with
+// the extra provider network pop tunnels terminate on if popProviderIP is set.
+func overlayProviderNfn(providerIP string, popProviderIP string, ovnIP string) []*utils.ICNNfnConfig {
+	nfn := []*utils.ICNNfnConfig{
+		{
+			DefaultGateway: false,
+			Interface:      "net2",
+			IPAddress:      providerIP,
+			Name:           "pnetwork",
+			Separate:       ",",
+			Namespace:      "sdewan-system",
+		},
 	}
-
-	log.Println("Successfully set cluster role as " + clusterRole + ".")
+	if popProviderIP != "" {
+		nfn = append(nfn, &utils.ICNNfnConfig{
+			DefaultGateway: false,
+			Interface:      "net3",
+			IPAddress:      popProviderIP,
+			Name:           "pnetwork",
+			Separate:       ",",
+			Namespace:      "sdewan-system",
+		})
+	}
+	return append(nfn, &utils.ICNNfnConfig{
+		DefaultGateway: false,
+		Interface:      "net0",
+		IPAddress:      ovnIP,
+		Name:           "ovn-network",
+		Separate:       "",
+		Namespace:      "sdewan-system",
+	})
 }
 
-func initDataplane(nfnSettings []*utils.ICNNfnConfig, publicIP string, clusterRole string) {
+// initDataplane deploys CNF and CRD controllers. popPublicIP is the address a
+// popoverlay cluster terminates tunnels on, besides publicIP of the overlay controller.
+func initDataplane(nfnSettings []*utils.ICNNfnConfig, publicIP string, popPublicIP string, clusterRole string) {
 	helmWorkingDir := filepath.Join(sasectlConf.ICNSdewanFilePath, "platform/deployment/helm")
 	certWorkingDir := filepath.Join(helmWorkingDir, "cert")
 	cnfValueFp := filepath.Join(helmWorkingDir, "sdewan_cnf/values.yaml")
 	cmFp := filepath.Join(helmWorkingDir, "sdewan_cnf/templates/cm.yaml")
 
-	cnfValue := utils.LoadCNFValueFile(cnfValueFp)
-	cnfValue["nfn"] = nfnSettings
-	cnfValue["publicIpAddress"] = publicIP
+	cnfValue := initCNFValue(cnfValueFp, nfnSettings, publicIP, popPublicIP)
+	cnfValue["routeTable"] = utils.CNFRouteTable
 	utils.UpdateCNFValueFile(cnfValueFp, cnfValue)
 
 	utils.GenerateCMYaml(cmFp, clusterRole)
@@ -272,67 +351,202 @@ func initDataplane(nfnSettings []*utils.ICNNfnConfig, publicIP string, clusterRo
 		output, err := cmd.CombinedOutput()
 		log.Println(string(output))
 		if err != nil {
-			log.Fatal(err)
+			utils.Fatal(err)
 			return
 		}
+		utils.AuditCmd(item)
 	}
+
+	log.Println("Waiting for data plane to be ready")
+	waitObjectsReady(dataplaneObjects())
 }
 
-func exportKubeConfig() {
-	homeDir, err := os.UserHomeDir()
+// dataplaneObjects lists CNF certificates and objects of CNF & controller charts.
+func dataplaneObjects() []utils.K8sObjectRef {
+	certFp := filepath.Join(sasectlConf.ICNSdewanFilePath, "platform/deployment/helm/cert/cnf_cert.yaml")
+	objs, err := utils.LoadManifestObjects(certFp, "")
 	if err != nil {
-		log.Fatal("Failed to export kube config file.")
-		log.Fatal(err)
+		utils.Fatal(err)
+	}
+	for _, release := range []string{sasectlConf.ICNSdewanCNFChartName, sasectlConf.ICNSdewanCtrlChartName} {
+		releaseObjs, err := utils.GetHelmReleaseObjects(release)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		objs = append(objs, releaseObjs...)
 	}
-	kubeConfigFp := filepath.Join(homeDir, "/.kube/config")
+	return objs
+}
 
-	var outFile strings.Builder
-	apiServerCmd := exec.Command("kubectl", "config", "view", "-o", "jsonpath='{range .clusters[*]}{.cluster.server}{\"\\n\"}{end}'")
-	apiServerb, err := apiServerCmd.CombinedOutput()
+// overlayControllerObjects lists objects of SCC, rsync, etcd and mongo deployments.
+func overlayControllerObjects() []utils.K8sObjectRef {
+	overlayWorkingDir := filepath.Join(sasectlConf.ICNSdewanFilePath, "central-controller/deployments/kubernetes")
+	var objs []utils.K8sObjectRef
+	for _, f := range []string{"scc_mongo.yaml", "scc_etcd.yaml", "scc_rsync.yaml", "scc_secret.yaml", "scc.yaml"} {
+		fileObjs, err := utils.LoadManifestObjects(filepath.Join(overlayWorkingDir, f), utils.NameSpaceName)
+		if err != nil {
+			utils.Fatal(err)
+		}
+		objs = append(objs, fileObjs...)
+	}
+	return objs
+}
+
+func waitObjectsReady(objs []utils.K8sObjectRef) {
+	err := utils.WaitObjectsReady(objs, rolloutTimeout)
+	if err != nil {
+		utils.Fatal(err)
+	}
+}
+
+func waitObjectsDeleted(objs []utils.K8sObjectRef) {
+	err := utils.WaitObjectsDeleted(objs, rolloutTimeout)
 	if err != nil {
-		log.Fatal("Failed to export kube config file.")
-		log.Fatal(err)
+		utils.Fatal(err)
+	}
+}
+
+// initCNFValue returns CNF values of file fp with networks and public IPs set.
+func initCNFValue(fp string, nfnSettings []*utils.ICNNfnConfig, publicIP string, popPublicIP string) utils.CNFValue {
+	cnfValue := utils.LoadCNFValueFile(fp)
+	cnfValue["nfn"] = nfnSettings
+	cnfValue["publicIpAddress"] = publicIP
+	setPopPublicIP(cnfValue, popPublicIP)
+	setAPIServiceIP6(cnfValue, publicIP)
+	return cnfValue
+}
+
+// setAPIServiceIP6 sets the IPv6 address of kube API service, which CNF DNATs
+// an IPv6 public IP to.
+func setAPIServiceIP6(cnfValue utils.CNFValue, publicIP string) {
+	delete(cnfValue, "apiServiceIP6")
+	if !utils.IsIPv6(publicIP) {
+		return
+	}
+	output, err := exec.Command("kubectl", "get", "svc", "kubernetes", "-n", "default", "-o", "jsonpath={.spec.clusterIPs[*]}").CombinedOutput()
+	if err == nil {
+		for _, ip := range strings.Fields(string(output)) {
+			if utils.IsIPv6(ip) {
+				cnfValue["apiServiceIP6"] = ip
+				return
+			}
+		}
+	}
+	log.Println("Kube API service has no IPv6 address, it is not reachable on public IP " + publicIP + ".")
+}
+
+func setPopPublicIP(cnfValue utils.CNFValue, popPublicIP string) {
+	if popPublicIP == "" {
+		delete(cnfValue, "popPublicIpAddress")
+		return
 	}
-	apiServer := string(apiServerb)[9:]
-	apiServer = apiServer[:len(apiServer)-7]
-	outFile.WriteString(apiServer)
-	outFile.WriteString("-")
-	outFile.WriteString(sasectlConf.ICNSdewanRole)
+	cnfValue["popPublicIpAddress"] = popPublicIP
+}
 
-	cwd, err := os.Getwd()
+// parseOVNIP returns the address of CNF in the default OVN subnet of the
+// family of providerIP.
+func parseOVNIP(providerIP string) (string, error) {
+	ovnSubnet := defaultOVNSubnet
+	if utils.IsIPv6(providerIP) {
+		ovnSubnet = defaultOVNSubnet6
+	}
+	ovnIP, err := ovnIPInSubnet(providerIP, ovnSubnet)
 	if err != nil {
-		log.Fatal("Failed to export kube config file.")
-		log.Fatal(err)
+		return "", errors.New("Invalid provider IP: " + err.Error())
 	}
-	outFp := filepath.Join(cwd, outFile.String())
+	return ovnIP, nil
+}
 
-	src, err := os.Open(kubeConfigFp)
+// ovnIPInSubnet returns the address of CNF in OVN subnet, which has the host
+// part of its provider IP in a /24 for IPv4 or a /64 for IPv6, e.g. the last
+// octet for IPv4.
+func ovnIPInSubnet(providerIP string, ovnSubnet string) (string, error) {
+	ip := net.ParseIP(providerIP)
+	if ip == nil {
+		return "", errors.New("invalid IP address " + providerIP)
+	}
+	_, subnet, err := net.ParseCIDR(ovnSubnet)
 	if err != nil {
-		log.Fatal(err)
+		return "", errors.New("invalid OVN subnet " + ovnSubnet)
+	}
+	if !utils.SameIPFamily(ip, subnet.IP) {
+		return "", errors.New("OVN subnet " + ovnSubnet + " must be " + utils.IPFamily(ip) + " as provider IP " + providerIP)
+	}
+	ones, bits := subnet.Mask.Size()
+	hostPrefix := utils.DefaultIPRangePrefix
+	if bits == 8*net.IPv6len {
+		hostPrefix = utils.DefaultIPRangePrefix6
+	}
+	if ones > hostPrefix {
+		return "", errors.New("OVN subnet " + ovnSubnet + " must have prefix length up to " + strconv.Itoa(hostPrefix))
 	}
-	defer src.Close()
-	dst, err := os.Create(outFp)
+	ovnIP, err := utils.HostInNetwork(&net.IPNet{IP: subnet.IP, Mask: net.CIDRMask(hostPrefix, bits)}, ip)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
-	defer dst.Close()
-	_, err = io.Copy(dst, src)
+	return ovnIP.String(), nil
+}
 
+// dualStackNfn adds networks of IPv6 provider address providerIP6 and its OVN
+// address in ovnSubnet6 to nfn of IPv4 provider addresses, for a dual-stack CNF.
+func dualStackNfn(nfn []*utils.ICNNfnConfig, providerIP6 string, ovnSubnet6 string) ([]*utils.ICNNfnConfig, error) {
+	if providerIP6 == "" {
+		return nfn, nil
+	}
+	if !utils.IsIPv6(providerIP6) {
+		return nil, errors.New("Invalid IPv6 provider IP " + providerIP6)
+	}
+	for _, n := range nfn {
+		if utils.IsIPv6(n.IPAddress) {
+			return nil, errors.New("CNF already has IPv6 address " + n.IPAddress + ", don't set an extra IPv6 provider IP")
+		}
+	}
+	ovnIP6, err := ovnIPInSubnet(providerIP6, ovnSubnet6)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
+	nfn = append(nfn,
+		&utils.ICNNfnConfig{
+			DefaultGateway: false,
+			Interface:      "net4",
+			IPAddress:      providerIP6,
+			Name:           "pnetwork",
+			Namespace:      "sdewan-system",
+		},
+		&utils.ICNNfnConfig{
+			DefaultGateway: false,
+			Interface:      "net1",
+			IPAddress:      ovnIP6,
+			Name:           "ovn-network",
+			Namespace:      "sdewan-system",
+		})
+	setNfnSeparators(nfn)
+	return nfn, nil
 }
 
-func parseOVNIP(providerIP string) string {
-	ovnSubnet := "172.16.70.0/24"
-	ipField := strings.Split(providerIP, ".")
-	if len(ipField) != 4 {
-		log.Panic("Invalid provider IP")
+// setNfnSeparators sets the separators of nfn entries in the network annotation
+// of CNF, a comma after each entry but the last.
+func setNfnSeparators(nfn []*utils.ICNNfnConfig) {
+	for i, n := range nfn {
+		n.Separate = ","
+		if i == len(nfn)-1 {
+			n.Separate = ""
+		}
 	}
-	ipSuffix := ipField[len(ipField)-1]
-	ovnPrefix := strings.Split(ovnSubnet, ".")
-	ovnIP := strings.Join(ovnPrefix[:3], ".")
-	ovnIP = ovnIP + "." + ipSuffix
-	return ovnIP
+}
 
+// initDualStackNfn adds networks of --providerIP6 to nfn, with the OVN address
+// in the IPv6 OVN network of default-networks.yaml.
+func initDualStackNfn(nfn []*utils.ICNNfnConfig) []*utils.ICNNfnConfig {
+	if initProviderIP6 == "" {
+		return nfn
+	}
+	// Without the file, the OVN address is in the default subnet.
+	networks, _ := utils.LoadOVNNetworks(filepath.Join(sasectlConf.ICNSdewanFilePath, "default-networks.yaml"))
+	nfn, err := dualStackNfn(nfn, initProviderIP6, ovnSubnetOfFamily(networks, true))
+	if err != nil {
+		utils.Fatal(err)
+	}
+	return nfn
 }
@@ -0,0 +1,399 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sasectl/utils"
+	"strings"
+	"text/tabwriter"
+)
+
+const defaultInitAnswersFP = "sasectl-init.yaml"
+
+var initRoles = []string{"edge", "pop", "overlay", "popoverlay"}
+
+// initSettings are the CNF settings of a role, resolved from init answers.
+type initSettings struct {
+	role        string
+	nfn         []*utils.ICNNfnConfig
+	publicIP    string
+	popPublicIP string
+}
+
+// initFromAnswers initializes cluster with answers of file answersFp, and
+// prompts for them first if interactive. Answers of the file are the defaults
+// of prompts, and the final answers are written back to it.
+func initFromAnswers(interactive bool, answersFp string) {
+	networks := initOVNNetworks()
+	answers := &utils.InitAnswers{}
+	if answersFp != "" {
+		loaded, err := utils.LoadInitAnswers(answersFp)
+		if err == nil {
+			answers = loaded
+		} else if !interactive || !os.IsNotExist(err) {
+			utils.Fatal(err)
+		}
+	}
+	if interactive {
+		promptInitAnswers(answers, networks)
+	}
+	settings, err := resolveInitAnswers(answers, networks)
+	if err != nil {
+		utils.Fatal(err)
+	}
+	printInitSettings(settings)
+
+	if interactive {
+		if answersFp == "" {
+			answersFp = defaultInitAnswersFP
+		}
+		err := utils.SaveInitAnswers(answersFp, answers)
+		if err != nil {
+			utils.Fatal(err)
+		}
+		log.Println("Answers are written to " + answersFp + ", repeat with sasectl init --answers " + answersFp + ".")
+		if !confirm("Initialize cluster as " + settings.role + "?") {
+			log.Println("Init is cancelled.")
+			return
+		}
+	}
+
+	switch settings.role {
+	case "edge":
+		initEdgeCluster(settings.nfn, settings.publicIP, false)
+	case "pop":
+		initPopCluster(settings.nfn, settings.publicIP)
+	case "overlay":
+		log.Println("Initialize cluster as Overlay")
+		initOverlayCluster(settings.nfn, settings.publicIP, "")
+	case "popoverlay":
+		log.Println("Initialize cluster as pop & overlay")
+		initOverlayCluster(settings.nfn, settings.publicIP, settings.popPublicIP)
+	}
+}
+
+// initOVNNetworks returns networks of default-networks.yaml, which answers are
+// validated against. Nil if they can't be read.
+func initOVNNetworks() []utils.OVNNetwork {
+	fp := filepath.Join(sasectlConf.ICNSdewanFilePath, "default-networks.yaml")
+	networks, err := utils.LoadOVNNetworks(fp)
+	if err != nil {
+		log.Println("Addresses are not validated against networks: " + err.Error())
+		return nil
+	}
+	return networks
+}
+
+func promptInitAnswers(answers *utils.InitAnswers, networks []utils.OVNNetwork) {
+	printHostNetworks(networks)
+
+	answers.Role = prompt("Role, one of "+strings.Join(initRoles, ", "), answers.Role, func(role string) error {
+		if !containsString(initRoles, role) {
+			return errors.New("unknown role " + role)
+		}
+		return nil
+	})
+	answers.ProviderIP = prompt("Provider IP of CNF", answers.ProviderIP, func(ip string) error {
+		_, err := validateProviderIP(ip, networks)
+		return err
+	})
+	if answers.Role == "popoverlay" {
+		answers.PopProviderIP = prompt("Provider IP pop tunnels terminate on", answers.PopProviderIP, func(ip string) error {
+			_, err := validateProviderIP(ip, networks)
+			return err
+		})
+	} else {
+		answers.PopProviderIP = ""
+	}
+	publicIP := answers.PublicIP
+	if publicIP == "" {
+		publicIP = answers.ProviderIP
+	}
+	answers.PublicIP = prompt("Public IP of CNF", publicIP, func(ip string) error {
+		return checkIP(ip, "public IP")
+	})
+	answers.OVNSubnet = prompt("OVN subnet", initOVNSubnet(answers, networks), func(subnet string) error {
+		_, _, err := validateOVNSubnet(subnet, answers.ProviderIP, networks)
+		return err
+	})
+	if utils.IsIPv6(answers.ProviderIP) {
+		answers.ProviderIP6 = ""
+		return
+	}
+	promptProviderIP6(answers, networks)
+}
+
+// promptProviderIP6 asks for an optional IPv6 provider IP of a dual-stack CNF.
+func promptProviderIP6(answers *utils.InitAnswers, networks []utils.OVNNetwork) {
+	answers.ProviderIP6 = prompt("IPv6 provider IP of dual-stack CNF, none for IPv4 only", answers.ProviderIP6, func(ip string) error {
+		if ip == "" {
+			return nil
+		}
+		_, _, err := validateProviderIP6(ip, networks)
+		return err
+	})
+}
+
+// prompt asks question until check accepts the answer. def is taken for an
+// empty answer.
+func prompt(question string, def string, check func(string) error) string {
+	for {
+		if def != "" {
+			fmt.Printf("%s [%s]: ", question, def)
+		} else {
+			fmt.Print(question + ": ")
+		}
+		answer, readErr := stdinReader.ReadString('\n')
+		answer = strings.TrimSpace(answer)
+		if answer == "" {
+			answer = def
+		}
+		err := check(answer)
+		if err == nil {
+			return answer
+		}
+		if readErr != nil {
+			utils.Fatal("Invalid answer to " + question + ": " + err.Error())
+		}
+		fmt.Println("  " + err.Error())
+	}
+}
+
+// resolveInitAnswers validates answers and returns CNF settings of the role.
+func resolveInitAnswers(answers *utils.InitAnswers, networks []utils.OVNNetwork) (*initSettings, error) {
+	settings := &initSettings{role: answers.Role}
+	switch answers.Role {
+	case "edge", "pop", "overlay", "popoverlay":
+	case "":
+		return nil, errors.New("Role is not set in answers")
+	default:
+		return nil, errors.New("Unknown role " + answers.Role + ", use one of " + strings.Join(initRoles, ", "))
+	}
+
+	pnet, err := validateProviderIP(answers.ProviderIP, networks)
+	if err != nil {
+		return nil, errors.New("Invalid provider IP: " + err.Error())
+	}
+	settings.publicIP = answers.PublicIP
+	if settings.publicIP == "" && answers.Role != "edge" {
+		settings.publicIP = answers.ProviderIP
+	}
+	err = checkIP(settings.publicIP, "public IP")
+	if err != nil {
+		return nil, errors.New("Invalid public IP: " + err.Error())
+	}
+	onet, ovnIP, err := validateOVNSubnet(initOVNSubnet(answers, networks), answers.ProviderIP, networks)
+	if err != nil {
+		return nil, errors.New("Invalid OVN subnet: " + err.Error())
+	}
+
+	var popNet *utils.OVNNetwork
+	switch answers.Role {
+	case "edge", "pop":
+		settings.nfn = providerNfn(answers.ProviderIP, ovnIP)
+	case "overlay":
+		settings.nfn = overlayProviderNfn(answers.ProviderIP, "", ovnIP)
+	case "popoverlay":
+		popNet, err = validateProviderIP(answers.PopProviderIP, networks)
+		if err != nil {
+			return nil, errors.New("Invalid pop provider IP: " + err.Error())
+		}
+		settings.nfn = overlayProviderNfn(answers.ProviderIP, answers.PopProviderIP, ovnIP)
+		settings.popPublicIP = answers.PopProviderIP
+	}
+	if pnet != nil {
+		settings.nfn[0].Name = pnet.Name
+	}
+	if popNet != nil {
+		settings.nfn[1].Name = popNet.Name
+	}
+	if onet != nil {
+		settings.nfn[len(settings.nfn)-1].Name = onet.Name
+	}
+	return settings, resolveProviderIP6(settings, answers, networks)
+}
+
+// resolveProviderIP6 adds networks of the IPv6 provider IP of answers to CNF
+// settings, if it is dual-stack.
+func resolveProviderIP6(settings *initSettings, answers *utils.InitAnswers, networks []utils.OVNNetwork) error {
+	if answers.ProviderIP6 == "" {
+		return nil
+	}
+	pnet, onet, err := validateProviderIP6(answers.ProviderIP6, networks)
+	if err != nil {
+		return errors.New("Invalid IPv6 provider IP: " + err.Error())
+	}
+	settings.nfn, err = dualStackNfn(settings.nfn, answers.ProviderIP6, ovnSubnetOfFamily(networks, true))
+	if err != nil {
+		return err
+	}
+	n := len(settings.nfn)
+	if pnet != nil {
+		settings.nfn[n-2].Name = pnet.Name
+	}
+	if onet != nil {
+		settings.nfn[n-1].Name = onet.Name
+	}
+	return nil
+}
+
+// validateProviderIP checks that ip is a free address of a provider network, and
+// returns the network if networks are known.
+func validateProviderIP(ip string, networks []utils.OVNNetwork) (*utils.OVNNetwork, error) {
+	err := checkIP(ip, "provider IP")
+	if err != nil {
+		return nil, err
+	}
+	addr := net.ParseIP(ip)
+	pnet, err := findOVNNetwork(networks, "ProviderNetwork", func(n *utils.OVNNetwork) bool {
+		return n.Subnet.Contains(addr)
+	})
+	if err != nil {
+		return nil, errors.New(ip + " is not in a provider network, " + err.Error())
+	}
+	if pnet != nil && pnet.Excludes(addr) {
+		return nil, errors.New(ip + " is reserved in provider network " + pnet.Name + ": gateway and " + pnet.ExcludeIPs)
+	}
+	user, err := utils.FindIPUser(ip)
+	if err == nil && user != "" {
+		return nil, errors.New(ip + " is in use by " + user)
+	}
+	return pnet, nil
+}
+
+// validateProviderIP6 checks the IPv6 provider IP of a dual-stack CNF and its
+// OVN address, and returns their networks if networks are known.
+func validateProviderIP6(ip string, networks []utils.OVNNetwork) (*utils.OVNNetwork, *utils.OVNNetwork, error) {
+	if !utils.IsIPv6(ip) {
+		return nil, nil, errors.New("invalid IPv6 address " + ip)
+	}
+	pnet, err := validateProviderIP(ip, networks)
+	if err != nil {
+		return nil, nil, err
+	}
+	onet, _, err := validateOVNSubnet(ovnSubnetOfFamily(networks, true), ip, networks)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pnet, onet, nil
+}
+
+// validateOVNSubnet checks that subnet is an OVN network, and returns the
+// network if networks are known, and the address of CNF in it.
+func validateOVNSubnet(subnet string, providerIP string, networks []utils.OVNNetwork) (*utils.OVNNetwork, string, error) {
+	ovnIP, err := ovnIPInSubnet(providerIP, subnet)
+	if err != nil {
+		return nil, "", err
+	}
+	_, ipNet, _ := net.ParseCIDR(subnet)
+	onet, err := findOVNNetwork(networks, "Network", func(n *utils.OVNNetwork) bool {
+		return n.Subnet.String() == ipNet.String()
+	})
+	if err != nil {
+		return nil, "", errors.New(subnet + " is not an OVN network, " + err.Error())
+	}
+	if onet != nil && onet.Excludes(net.ParseIP(ovnIP)) {
+		return nil, "", errors.New("OVN address " + ovnIP + " is reserved in network " + onet.Name + ", use a provider IP with another host part")
+	}
+	user, err := utils.FindIPUser(ovnIP)
+	if err == nil && user != "" {
+		return nil, "", errors.New("OVN address " + ovnIP + " is in use by " + user)
+	}
+	return onet, ovnIP, nil
+}
+
+// findOVNNetwork returns the network of kind which matches. No network and no
+// error are returned if networks of kind are unknown.
+func findOVNNetwork(networks []utils.OVNNetwork, kind string, match func(*utils.OVNNetwork) bool) (*utils.OVNNetwork, error) {
+	var subnets []string
+	for i := range networks {
+		n := &networks[i]
+		if n.Kind != kind {
+			continue
+		}
+		if match(n) {
+			return n, nil
+		}
+		subnets = append(subnets, n.Name+" "+n.Subnet.String())
+	}
+	if len(subnets) == 0 {
+		return nil, nil
+	}
+	return nil, errors.New("use one of " + strings.Join(subnets, ", "))
+}
+
+// initOVNSubnet returns OVN subnet of answers, the first OVN network of the
+// family of provider IP if not set.
+func initOVNSubnet(answers *utils.InitAnswers, networks []utils.OVNNetwork) string {
+	if answers.OVNSubnet != "" {
+		return answers.OVNSubnet
+	}
+	return ovnSubnetOfFamily(networks, utils.IsIPv6(answers.ProviderIP))
+}
+
+// ovnSubnetOfFamily returns the first IPv4 or IPv6 OVN network, the default
+// subnet of the sdewan ansible role if there is none.
+func ovnSubnetOfFamily(networks []utils.OVNNetwork, ipv6 bool) string {
+	for _, n := range networks {
+		if n.Kind == "Network" && (n.Subnet.IP.To4() == nil) == ipv6 {
+			return n.Subnet.String()
+		}
+	}
+	if ipv6 {
+		return defaultOVNSubnet6
+	}
+	return defaultOVNSubnet
+}
+
+func printHostNetworks(networks []utils.OVNNetwork) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	addrs, err := utils.HostAddresses()
+	if err != nil {
+		log.Println("Failed to list host addresses: " + err.Error())
+	}
+	if len(addrs) > 0 {
+		fmt.Fprintln(w, "HOST INTERFACE\tADDRESS\t")
+		for _, addr := range addrs {
+			fmt.Fprintf(w, "%s\t%s\t\n", addr.Interface, addr.Address.String())
+		}
+		fmt.Fprintln(w, "\t\t")
+	}
+	if len(networks) > 0 {
+		fmt.Fprintln(w, "NETWORK\tSUBNET\tRESERVED\tHOST INTERFACE")
+		for _, n := range networks {
+			reserved := n.ExcludeIPs
+			if n.Gateway != nil {
+				reserved = n.Gateway.String() + ", " + reserved
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", n.Name, n.Subnet.String(), dashIfEmpty(reserved), dashIfEmpty(n.Interface))
+		}
+	}
+	w.Flush()
+}
+
+// printInitSettings shows networks of CNF and the values.yaml init generates.
+func printInitSettings(settings *initSettings) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tINTERFACE\tIP ADDRESS\tDEFAULT GATEWAY")
+	for _, nfn := range settings.nfn {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\n", nfn.Name, nfn.Interface, nfn.IPAddress, nfn.DefaultGateway)
+	}
+	w.Flush()
+
+	cnfValueFp := filepath.Join(sasectlConf.ICNSdewanFilePath, "platform/deployment/helm/sdewan_cnf/values.yaml")
+	cnfValue := initCNFValue(cnfValueFp, settings.nfn, settings.publicIP, settings.popPublicIP)
+	data, err := utils.MarshalCNFValue(cnfValue)
+	if err != nil {
+		utils.Fatal(err)
+	}
+	fmt.Println("\nsdewan_cnf/values.yaml, routeTable is set by init:")
+	fmt.Println(string(data))
+}
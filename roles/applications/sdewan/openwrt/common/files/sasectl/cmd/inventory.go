@@ -0,0 +1,249 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sasectl/utils"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/akraino-edge-stack/icn-sdwan/central-controller/src/scc/pkg/module"
+)
+
+// inventoryEntry is an inventory device with its resolved type and result.
+type inventoryEntry struct {
+	utils.InventoryDevice
+	result string
+	err    error
+}
+
+// overlayObjectNames holds names of objects which exist in an overlay, so that
+// a retried registration skips them.
+type overlayObjectNames struct {
+	hubs  map[string]bool
+	devs  map[string]bool
+	certs map[string]bool
+	cons  map[string]bool
+}
+
+// regInventory registers pops first, then edges with their certificates and
+// connections to pops, parallel devices at a time. Devices done in an earlier
+// run, as recorded in statePath, are skipped.
+func regInventory(inventoryFp string, statePath string, parallel int) {
+	devices, err := utils.LoadInventory(inventoryFp)
+	if err != nil {
+		utils.Fatal(err)
+	}
+	if statePath == "" {
+		statePath = inventoryFp + ".state.json"
+	}
+	state, err := utils.LoadInventoryState(statePath)
+	if err != nil {
+		utils.Fatal(err)
+	}
+	if parallel < 1 {
+		parallel = 1
+	}
+	serverUrl := getSCCServerUrl()
+	providerIPs := regLocalProviderIPs()
+
+	var entries []*inventoryEntry
+	existing := make(map[string]*overlayObjectNames)
+	for _, d := range devices {
+		e := &inventoryEntry{InventoryDevice: d}
+		entries = append(entries, e)
+		if e.Overlay == "" {
+			e.Overlay = "overlay1"
+		}
+		if state.Devices[e.Name].Status == utils.InventoryDone {
+			e.result = "skipped"
+			continue
+		}
+		e.Type, e.PublicIP, e.err = resolveDevice(e.File, e.Type, e.PublicIP)
+		if e.err != nil {
+			continue
+		}
+		if _, ok := existing[e.Overlay]; !ok {
+			existing[e.Overlay], err = queryOverlayObjectNames(serverUrl, e.Overlay)
+			if err != nil {
+				utils.Fatal(err)
+			}
+		}
+	}
+
+	var mutex sync.Mutex
+	record := func(e *inventoryEntry) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		res := utils.InventoryResult{Status: utils.InventoryDone, Updated: time.Now().UTC()}
+		e.result = "registered"
+		if e.err != nil {
+			res.Status = utils.InventoryFailed
+			res.Error = e.err.Error()
+			e.result = utils.InventoryFailed
+		}
+		state.Devices[e.Name] = res
+		err := utils.SaveInventoryState(statePath, state)
+		if err != nil {
+			log.Println("Failed to save inventory state: " + err.Error())
+		}
+	}
+
+	// Connections of edges need their pops, so pops are registered first.
+	for _, pops := range []bool{true, false} {
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, parallel)
+		for _, e := range entries {
+			if e.result != "" || e.err != nil || (e.Type != "edge") != pops {
+				continue
+			}
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(e *inventoryEntry) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				log.Printf("Registering %s %s on overlay %s ...", e.Type, e.Name, e.Overlay)
+				e.err = regInventoryDevice(serverUrl, providerIPs, e, existing[e.Overlay])
+				record(e)
+			}(e)
+		}
+		wg.Wait()
+	}
+	for _, e := range entries {
+		if e.result == "" && e.err != nil {
+			record(e)
+		}
+	}
+
+	synced := make(map[string]bool)
+	for _, e := range entries {
+		if e.result == "registered" && !synced[e.Overlay] {
+			synced[e.Overlay] = true
+			regSyncPeerRules(serverUrl, e.Overlay)
+		}
+	}
+
+	if printInventoryResults(entries) {
+		log.Println("Fix the failures and run again to retry failed devices, state is kept in " + statePath)
+		utils.Exit(1, "Some devices failed to register")
+	}
+}
+
+func regInventoryDevice(serverUrl string, providerIPs []string, e *inventoryEntry, names *overlayObjectNames) error {
+	if e.Type == "edge" {
+		overlayIP, err := overlayIPOfFamily(providerIPs, e.PublicIP)
+		if err != nil {
+			return err
+		}
+		if !names.certs[e.Name] {
+			err := regCert(serverUrl, e.Overlay, e.Name)
+			if err != nil {
+				return errors.New("Failed to create certificate: " + err.Error())
+			}
+		}
+		if !names.devs[e.Name] {
+			err := regDevice(serverUrl, e.Overlay, e.Name, e.File)
+			if err != nil {
+				return errors.New("Failed to create device: " + err.Error())
+			}
+		}
+		err = writeEdgeIpsecInfo(serverUrl, e.Overlay, overlayIP, e.Name)
+		if err != nil {
+			return err
+		}
+		for _, pop := range e.Pops {
+			// names.cons holds connection names of SCC, not of hub-device objects.
+			if names.cons[hubDeviceConnectionName(pop, e.Name)] {
+				continue
+			}
+			conUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection +
+				"/" + e.Overlay + "/" + utils.HubCollection + "/" + pop + "/" + utils.DeviceCollection
+			conObj := module.HubDeviceObject{
+				Metadata: module.ObjectMetaData{Name: hubDeviceObjectName(pop, e.Name)},
+				Specification: module.HubDeviceObjectSpec{
+					Device:        e.Name,
+					IsDelegateHub: true,
+				},
+			}
+			_, err := createControllerObject(conUrl, &conObj, &module.HubDeviceObject{})
+			if err != nil {
+				return errors.New("Failed to connect to pop " + pop + ": " + err.Error())
+			}
+		}
+	} else {
+		if len(e.Pops) > 0 {
+			log.Printf("Pops of %s %s are ignored, only edges connect to pops.", e.Type, e.Name)
+		}
+		if e.PublicIP == "" {
+			return errors.New("Public IP of pop " + e.Name + " is unknown, set publicIP in inventory")
+		}
+		if !names.hubs[e.Name] {
+			err := regHub(serverUrl, e.Overlay, e.Name, e.File, []string{e.PublicIP})
+			if err != nil {
+				return errors.New("Failed to create pop: " + err.Error())
+			}
+		}
+	}
+	return exportCapem(e.Name)
+}
+
+func queryOverlayObjectNames(serverUrl string, overlay string) (*overlayObjectNames, error) {
+	names := &overlayObjectNames{
+		hubs:  make(map[string]bool),
+		devs:  make(map[string]bool),
+		certs: make(map[string]bool),
+		cons:  make(map[string]bool),
+	}
+	hubs, err := queryHubs(serverUrl, overlay)
+	if err != nil {
+		return nil, errors.New("Failed to query pops of overlay " + overlay)
+	}
+	for _, hub := range hubs {
+		names.hubs[hub.Metadata.Name] = true
+	}
+	devs, err := queryDevs(serverUrl, overlay)
+	if err != nil {
+		return nil, errors.New("Failed to query devices of overlay " + overlay)
+	}
+	for _, dev := range devs {
+		names.devs[dev.Metadata.Name] = true
+	}
+	certs, err := queryCerts(serverUrl, overlay)
+	if err != nil {
+		return nil, errors.New("Failed to query certificates of overlay " + overlay)
+	}
+	for _, cert := range certs {
+		names.certs[cert.Metadata.Name] = true
+	}
+	for _, c := range listConnections(serverUrl, overlay) {
+		names.cons[c.Metadata.Name] = true
+	}
+	return names, nil
+}
+
+// printInventoryResults prints a summary of devices, and returns whether any failed.
+func printInventoryResults(entries []*inventoryEntry) bool {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tOVERLAY\tRESULT\tDETAIL")
+	failed := 0
+	for _, e := range entries {
+		detail := ""
+		if e.err != nil {
+			detail = e.err.Error()
+			failed++
+		} else if e.result == "skipped" {
+			detail = "done in an earlier run"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.Name, dashIfEmpty(e.Type), e.Overlay, e.result, detail)
+	}
+	w.Flush()
+	log.Printf("%d devices, %d failed.", len(entries), failed)
+	return failed > 0
+}
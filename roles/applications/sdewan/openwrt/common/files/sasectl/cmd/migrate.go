@@ -0,0 +1,197 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sasectl/utils"
+
+	"github.com/spf13/cobra"
+)
+
+// migrations lists the role changes which only add to a cluster, so that
+// tunnels and SCC state survive.
+var migrations = map[string][]string{
+	"overlay": {"popoverlay"},
+	"pop":     {"popoverlay"},
+}
+
+var migrateExportOpts kubeConfigExportOptions
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Change cluster role without reset, keeping SCC state",
+	Long: `Change the role of an initialized cluster in place. Supported are overlay to
+popoverlay and pop to popoverlay: the extra provider network and kube API DNAT are
+added to CNF, SCC is deployed on a pop, and the kube config for registration is
+exported. Addresses of the CNF are kept, the one of the added role is given with
+--popProviderIP for an overlay and --providerIP for a pop. Other changes need
+sasectl reset and sasectl init.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		to, err := cmd.Flags().GetString("to")
+		if err != nil {
+			utils.Fatal(err)
+		}
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			utils.Fatal(err)
+		}
+		yes, err := cmd.Flags().GetBool("yes")
+		if err != nil {
+			utils.Fatal(err)
+		}
+		providerIP, publicIP := initProviderFlags(cmd)
+		popProviderIP, err := cmd.Flags().GetString("popProviderIP")
+		if err != nil {
+			utils.Fatal(err)
+		}
+
+		migrateCluster(to, providerIP, publicIP, popProviderIP, dryRun, yes)
+	},
+}
+
+func init() {
+	migrateCmd.Flags().String("to", "", "Role to migrate to: popoverlay")
+	migrateCmd.MarkFlagRequired("to")
+	migrateCmd.RegisterFlagCompletionFunc("to", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"popoverlay"}, cobra.ShellCompDirectiveNoFileComp
+	})
+	migrateCmd.Flags().String("providerIP", "", "IPv4 address added for overlay controller when migrating a pop, e.g. 10.10.70.49")
+	migrateCmd.Flags().String("publicIP", "", "Public ip address of overlay controller when migrating a pop, the provider IP if not set")
+	migrateCmd.Flags().String("popProviderIP", "", "IPv4 address added for pop tunnels when migrating an overlay, e.g. 10.10.70.39")
+	migrateCmd.Flags().Bool("dry-run", false, "Only show the changes")
+	migrateCmd.Flags().BoolP("yes", "y", false, "Migrate without asking for confirmation")
+	migrateCmd.Flags().DurationVar(&rolloutTimeout, "wait-timeout", utils.RolloutTimeout, "Maximum time to wait for deployments to be ready")
+	addKubeConfigExportFlags(migrateCmd, &migrateExportOpts, "export-", false)
+	rootCmd.AddCommand(migrateCmd)
+}
+
+func migrateCluster(to string, providerIP string, publicIP string, popProviderIP string, dryRun bool, yes bool) {
+	from := sasectlConf.ICNSdewanRole
+	if from == "" {
+		utils.Fatal("Cluster is not initialized, use sasectl init " + to + ".")
+	}
+	if from == to {
+		utils.Fatal("Cluster is already initialized as " + to + ".")
+	}
+	if !containsString(migrations[from], to) {
+		utils.Fatal("Migration from " + from + " to " + to + " is not supported, use sasectl reset and sasectl init " + to + ".")
+	}
+
+	helmWorkingDir := filepath.Join(sasectlConf.ICNSdewanFilePath, "platform/deployment/helm")
+	cnfValueFp := filepath.Join(helmWorkingDir, "sdewan_cnf/values.yaml")
+	cmFp := filepath.Join(helmWorkingDir, "sdewan_cnf/templates/cm.yaml")
+
+	oldProviderIPs := regLocalProviderIPs()
+	cnfValue := utils.LoadCNFValueFile(cnfValueFp)
+	nfn, err := cnfValue.Nfn()
+	if err != nil {
+		utils.Fatal(err)
+	}
+	// Tunnels and registrations use the addresses the cluster has, so they are
+	// kept and only the provider address of the added role is new.
+	var popPublicIP string
+	switch from {
+	case "overlay":
+		nfn, err = migrateNfn(nfn, popProviderIP, "--popProviderIP", initOVNNetworks())
+		popPublicIP = popProviderIP
+	case "pop":
+		nfn, err = migrateNfn(nfn, providerIP, "--providerIP", initOVNNetworks())
+		popPublicIP, _ = cnfValue["publicIpAddress"].(string)
+		cnfValue["publicIpAddress"] = publicIP
+	}
+	if err != nil {
+		utils.Fatal(err)
+	}
+	if popPublicIP == "" {
+		utils.Fatal("No public IP of pop in " + cnfValueFp)
+	}
+	cnfValue["nfn"] = nfn
+	setPopPublicIP(cnfValue, popPublicIP)
+
+	newValue, err := utils.MarshalCNFValue(cnfValue)
+	if err != nil {
+		utils.Fatal(err)
+	}
+	newCM, err := utils.RenderCMYaml(to)
+	if err != nil {
+		utils.Fatal(err)
+	}
+	cmDiff := upgradeDiff(cmFp, "sdewan_cnf/templates/cm.yaml", newCM)
+	fmt.Print(upgradeDiff(cnfValueFp, "sdewan_cnf/values.yaml", newValue) + cmDiff)
+	fmt.Printf("Migrate %s cluster to %s:\n", from, to)
+	fmt.Println("  upgrade releases " + sasectlConf.ICNSdewanCNFChartName + " and " + sasectlConf.ICNSdewanCtrlChartName)
+	if from == "pop" {
+		fmt.Println("  deploy SCC control plane")
+	}
+	fmt.Println("  export kube config of pop " + popPublicIP)
+
+	if dryRun {
+		return
+	}
+	if !yes && !confirm("Migrate cluster to "+to+"?") {
+		log.Println("Migration is cancelled.")
+		return
+	}
+
+	applyDataplaneUpgrade(newValue, newCM, cmDiff != "", nil)
+	if from == "pop" {
+		initControlPlane()
+	}
+	upgradeRouteIntent(oldProviderIPs, regLocalProviderIPs())
+	utils.SetClusterRole(configFP, to, sasectlConf)
+	exportKubeConfig(&migrateExportOpts, popPublicIP)
+
+	switch from {
+	case "overlay":
+		log.Println("SCC objects are kept. Register this cluster as pop with sasectl register overlay regDev -t popoverlay.")
+	case "pop":
+		log.Println("Pop keeps public IP " + popPublicIP + ", its registration on other overlay controllers stays valid.")
+	}
+	log.Println("Successfully migrated cluster role from " + from + " to " + to + ".")
+}
+
+// migrateNfn returns nfn with providerIP of flag added as provider network
+// net3 after net2, the network popoverlay has besides the ones of overlay or pop.
+// providerIP is validated against networks if they are known.
+func migrateNfn(nfn []*utils.ICNNfnConfig, providerIP string, flag string, networks []utils.OVNNetwork) ([]*utils.ICNNfnConfig, error) {
+	err := checkIP(providerIP, flag)
+	if err != nil {
+		return nil, err
+	}
+	pnet, err := validateProviderIP(providerIP, networks)
+	if err != nil {
+		return nil, errors.New("Invalid " + flag + ": " + err.Error())
+	}
+	var migrated []*utils.ICNNfnConfig
+	for _, n := range nfn {
+		if n.Interface == "net3" {
+			return nil, errors.New("CNF already has network net3 with address " + n.IPAddress)
+		}
+		migrated = append(migrated, n)
+		if n.Interface != "net2" {
+			continue
+		}
+		added := &utils.ICNNfnConfig{
+			DefaultGateway: false,
+			Interface:      "net3",
+			IPAddress:      providerIP,
+			Name:           n.Name,
+			Namespace:      n.Namespace,
+		}
+		if pnet != nil {
+			added.Name = pnet.Name
+		}
+		migrated = append(migrated, added)
+	}
+	if len(migrated) == len(nfn) {
+		return nil, errors.New("CNF has no provider network net2")
+	}
+	setNfnSeparators(migrated)
+	return migrated, nil
+}
@@ -9,8 +9,10 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"io/ioutil"
 	"log"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -43,11 +45,11 @@ var edgeRegToControllerCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		configFp, err := cmd.Flags().GetString("file")
 		if err != nil {
-			log.Fatal(err)
+			utils.Fatal(err)
 		}
 		certFp, err := cmd.Flags().GetString("ca")
 		if err != nil {
-			log.Fatal(err)
+			utils.Fatal(err)
 		}
 
 		regEdgeToOverlay(configFp, certFp)
@@ -67,13 +69,14 @@ var overlayPreRegCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		providerIPrange, err := cmd.Flags().GetString("providerIPrange")
 		if err != nil {
-			log.Fatal(err)
+			utils.Fatal(err)
 		}
 		dataIPrange, err := cmd.Flags().GetString("dataIPrange")
 		if err != nil {
-			log.Fatal(err)
+			utils.Fatal(err)
 		}
 
+		waitSCCReady(cmd)
 		regOverlayPreReg(providerIPrange, dataIPrange)
 	},
 }
@@ -84,14 +87,51 @@ var overlayRegDevCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		devName, err := cmd.Flags().GetString("name")
 		if err != nil {
-			log.Fatal(err)
+			utils.Fatal(err)
 		}
 
 		configFp, err := cmd.Flags().GetString("file")
 		if err != nil {
-			log.Fatal(err)
+			utils.Fatal(err)
 		}
-		regOverlayRegDev(configFp, "overlay1", devName)
+
+		devType, err := cmd.Flags().GetString("type")
+		if err != nil {
+			utils.Fatal(err)
+		}
+
+		publicIP, err := cmd.Flags().GetString("public-ip")
+		if err != nil {
+			utils.Fatal(err)
+		}
+
+		inventoryFp, err := cmd.Flags().GetString("inventory")
+		if err != nil {
+			utils.Fatal(err)
+		}
+
+		if inventoryFp != "" {
+			if devName != "" || configFp != "" {
+				utils.Fatal("--inventory can not be used with --name or --file.")
+			}
+			statePath, err := cmd.Flags().GetString("state")
+			if err != nil {
+				utils.Fatal(err)
+			}
+			parallel, err := cmd.Flags().GetInt("parallel")
+			if err != nil {
+				utils.Fatal(err)
+			}
+			waitSCCReady(cmd)
+			regInventory(inventoryFp, statePath, parallel)
+			return
+		}
+		if devName == "" || configFp == "" {
+			utils.Fatal("--name and --file are required, or use --inventory.")
+		}
+
+		waitSCCReady(cmd)
+		regOverlayRegDev(configFp, "overlay1", devName, devType, publicIP)
 	},
 }
 
@@ -101,19 +141,20 @@ var overlayRegConCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		overlay, err := cmd.Flags().GetString("overlay")
 		if err != nil {
-			log.Fatal(err)
+			utils.Fatal(err)
 		}
 
 		deviceName, err := cmd.Flags().GetString("device")
 		if err != nil {
-			log.Fatal(err)
+			utils.Fatal(err)
 		}
 
 		popName, err := cmd.Flags().GetString("pop")
 		if err != nil {
-			log.Fatal(err)
+			utils.Fatal(err)
 		}
 
+		waitSCCReady(cmd)
 		regOverlayCon(overlay, deviceName, popName)
 	},
 }
@@ -128,53 +169,84 @@ func init() {
 	edgeRegToControllerCmd.MarkFlagRequired("ca")
 	edgeRegToControllerCmd.MarkFlagFilename("ca")
 
+	registerOverlayCmd.PersistentFlags().IntVar(&routeTable, "route-table", 0, "Routing table for overlay policy routing, allocated if not set")
+	registerOverlayCmd.PersistentFlags().Bool("wait-ready", false, "Wait until SCC is ready before registration")
+	registerOverlayCmd.PersistentFlags().Duration("ready-timeout", utils.SCCReadyTimeout, "Maximum time to wait for SCC with --wait-ready")
 	registerOverlayCmd.AddCommand(overlayPreRegCmd)
 	registerOverlayCmd.AddCommand(overlayRegDevCmd)
 	registerOverlayCmd.AddCommand(overlayRegConCmd)
 
 	// Add flags to preReg cmd
-	overlayPreRegCmd.Flags().StringP("providerIPrange", "p", "192.168.0.0", "providerIPrange")
-	overlayPreRegCmd.Flags().StringP("dataIPrange", "d", "192.169.0.0", "dataIPrange")
+	overlayPreRegCmd.Flags().StringP("providerIPrange", "p", "192.168.0.0", "providerIPrange, a /24 IPv4 range, and an IPv6 range separated by comma to also route it for dual-stack, /64 if no prefix length is given")
+	overlayPreRegCmd.Flags().StringP("dataIPrange", "d", "192.169.0.0", "dataIPrange, a /24 IPv4 range")
 
 	// Add flags to regDev cmd
 	overlayRegDevCmd.Flags().StringP("file", "f", "", "Register info file export from sasectl init")
-	overlayRegDevCmd.MarkFlagRequired("file")
 	overlayRegDevCmd.MarkFlagFilename("file")
 	overlayRegDevCmd.Flags().StringP("name", "n", "", "Device name to register in overlay controller")
-	overlayRegDevCmd.MarkFlagRequired("name")
+	overlayRegDevCmd.Flags().StringP("type", "t", "", "Device type, one of edge|pop|popoverlay, read from register info file if not set")
+	overlayRegDevCmd.RegisterFlagCompletionFunc("type", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return utils.DeviceTypes, cobra.ShellCompDirectiveNoFileComp
+	})
+	overlayRegDevCmd.Flags().String("public-ip", "", "Public IP of pop, or of edge to pick the family of overlay controller address, read from register info file if not set")
+	overlayRegDevCmd.Flags().StringP("inventory", "i", "", "Register devices listed in a csv or yaml inventory file")
+	overlayRegDevCmd.MarkFlagFilename("inventory", "csv", "yaml", "yml")
+	overlayRegDevCmd.Flags().Int("parallel", 4, "Number of devices to register at a time with --inventory")
+	overlayRegDevCmd.Flags().String("state", "", "File recording registered devices with --inventory, <inventory>.state.json if not set")
 
 	// Add flags to regCon cmd
 	overlayRegConCmd.Flags().StringP("overlay", "o", "", "Overlay network to setup connection.")
 	overlayRegConCmd.MarkFlagRequired("name")
+	overlayRegConCmd.RegisterFlagCompletionFunc("overlay", completeSCC(utils.OverlayCollection))
 	overlayRegConCmd.Flags().StringP("device", "d", "", "Edge node to setup connection")
 	overlayRegConCmd.MarkFlagRequired("device")
+	overlayRegConCmd.RegisterFlagCompletionFunc("device", completeSCC(utils.DeviceCollection))
 	overlayRegConCmd.Flags().StringP("pop", "p", "", "Pop node to setup connection")
 	overlayRegConCmd.MarkFlagRequired("pop")
+	overlayRegConCmd.RegisterFlagCompletionFunc("pop", completeSCC(utils.HubCollection))
 
 	registerCmd.AddCommand(registerEdgeCmd)
 	registerCmd.AddCommand(registerOverlayCmd)
 	rootCmd.AddCommand(registerCmd)
 }
 
+func waitSCCReady(cmd *cobra.Command) {
+	waitReady, err := cmd.Flags().GetBool("wait-ready")
+	if err != nil {
+		utils.Fatal(err)
+	}
+	if !waitReady {
+		return
+	}
+	timeout, err := cmd.Flags().GetDuration("ready-timeout")
+	if err != nil {
+		utils.Fatal(err)
+	}
+	_, err = utils.WaitSCCReady(getSCCEndpoint(), timeout)
+	if err != nil {
+		utils.Fatal(err)
+	}
+}
+
 func regEdgeToOverlay(configFp string, certFp string) {
 	safePodName := utils.CheckPodFullname("safe")
 	caPem, err := ioutil.ReadFile(certFp)
 	if err != nil {
 		log.Println("Failed to read cert file.")
-		log.Fatal(err)
+		utils.Fatal(err)
 	}
 	block, _ := pem.Decode(caPem)
 	if block == nil {
-		log.Fatal("Invalid input PEM file.")
+		utils.Fatal("Invalid input PEM file.")
 	}
 
 	cert, err := x509.ParseCertificate(block.Bytes)
 	if err != nil {
-		log.Fatal("Invalid input PEM file.")
+		utils.Fatal("Invalid input PEM file.")
 	}
 
 	if cert.Issuer.CommonName != "sdewan-controller" {
-		log.Fatal("Invalid input PEM file.")
+		utils.Fatal("Invalid input PEM file.")
 		return
 	}
 
@@ -184,9 +256,10 @@ func regEdgeToOverlay(configFp string, certFp string) {
 	if err != nil {
 		log.Println("Failed to copy cert file to CNF pods")
 		log.Println(string(output))
-		log.Fatal(err)
+		utils.Fatal(err)
 	}
 	log.Print(string(output))
+	utils.AuditChange("cnf file /etc/ipsec.d/cacerts/ca.pem of " + safePodName)
 	regCmdInfo := utils.CmdInfo{
 		CmdName: "kubectl",
 		CmdArgs: []string{"apply", "-f", configFp},
@@ -195,13 +268,44 @@ func regEdgeToOverlay(configFp string, certFp string) {
 	output, err = regCmd.CombinedOutput()
 	if err != nil {
 		log.Println(string(output))
-		log.Fatal(err)
+		utils.Fatal(err)
 	}
 	log.Print(string(output))
+	utils.AuditCmd(regCmdInfo)
 }
 
 func regOverlayPreReg(providerIPrange string, dataIPrange string) {
-	serverIP := utils.CheckPodIP("scc")
+	providerIPranges, err := utils.SplitIPRanges(providerIPrange)
+	if err != nil {
+		utils.Fatal(err)
+	}
+	dataIPranges, err := utils.SplitIPRanges(dataIPrange)
+	if err != nil {
+		utils.Fatal(err)
+	}
+	// An IPv6 provider range is only routed on the host, SCC allocates from the
+	// IPv4 one.
+	var providerIPrange4 *net.IPNet
+	for _, ipRange := range providerIPranges {
+		if ipRange.IP.To4() != nil {
+			providerIPrange4 = ipRange
+		}
+	}
+	if providerIPrange4 == nil {
+		utils.Fatal("An IPv4 provider IP range is required by SCC")
+	}
+	err = utils.CheckSCCIPRange(providerIPrange4)
+	if err != nil {
+		utils.Fatal(err)
+	}
+	if len(dataIPranges) != 1 {
+		utils.Fatal("Only an IPv4 data IP range is supported by SCC")
+	}
+	err = utils.CheckSCCIPRange(dataIPranges[0])
+	if err != nil {
+		utils.Fatal(err)
+	}
+	serverUrl := getSCCServerUrl()
 	// 3 Nodes PreReg Con
 	// TODO: Provide more general way.
 	overlay := "overlay1"
@@ -216,87 +320,173 @@ func regOverlayPreReg(providerIPrange string, dataIPrange string) {
 		regCustomizeCombinedIptables()
 	}
 
-	regOverlay(serverIP, overlay)
-	regProposal(serverIP, overlay, overlayProposal1)
-	regProposal(serverIP, overlay, overlayProposal2)
-	regIPRange(serverIP, "", providerIPrange, providerIPrangeName)
-	regIPRange(serverIP, overlay, dataIPrange, dataIPRangeName)
+	regOverlay(serverUrl, overlay)
+	regProposal(serverUrl, overlay, overlayProposal1)
+	regProposal(serverUrl, overlay, overlayProposal2)
+	err = regIPRange(serverUrl, "", providerIPrange4.IP.String(), providerIPrangeName)
+	if err != nil {
+		utils.Fatal(err)
+	}
+	err = regIPRange(serverUrl, overlay, dataIPranges[0].IP.String(), dataIPRangeName)
+	if err != nil {
+		utils.Fatal(err)
+	}
 	regConfigSCCDB()
 	regCallRegCluster()
-	regSetIPRule(providerIPrange, "40")
+	regSetIPRule(serverUrl, overlay, providerIPranges, getRouteTable())
 
 	// DEBUG Check pre reg result.
-	overlayUrl := "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection
+	overlayUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection
 	overlayData, err := utils.CallRest("GET", overlayUrl, "")
 	if err != nil {
-		log.Fatal(err)
+		utils.Fatal(err)
 	}
 	log.Println(overlayData)
 
-	proposalUrl := "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection +
+	proposalUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection +
 		"/" + overlay + "/" + utils.ProposalCollection
 	proposalData, err := utils.CallRest("GET", proposalUrl, "")
 	if err != nil {
-		log.Fatal(err)
+		utils.Fatal(err)
 	}
 	log.Println(proposalData)
 
-	providerIpRangeUrl := "http://" + serverIP + ":9015/scc/v1/" + "provider" +
+	providerIpRangeUrl := serverUrl + "/scc/v1/" + "provider" +
 		"/" + utils.IPRangeCollection
 	providerIPData, err := utils.CallRest("GET", providerIpRangeUrl, "")
 	if err != nil {
-		log.Fatal(err)
+		utils.Fatal(err)
 	}
 	log.Println(providerIPData)
 
-	overlayIpRangeUrl := "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection +
+	overlayIpRangeUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection +
 		"/" + overlay + "/" + utils.IPRangeCollection
 	overlayIPData, err := utils.CallRest("GET", overlayIpRangeUrl, "")
 	if err != nil {
-		log.Fatal(err)
+		utils.Fatal(err)
 	}
 	log.Println(overlayIPData)
 }
 
-func regOverlayRegDev(configFP string, overlay string, deviceName string) {
-	serverIP := utils.CheckPodIP("scc")
-	if serverIP == "" {
-		log.Fatal("SCC Pod not found.")
-		return
-	}
-	_, confFileName := filepath.Split(configFP)
-	confInfo := strings.Split(confFileName, "-")
-	// devApiServer := confInfo[0]
-	if len(confInfo) <= 1 {
-		log.Fatal("Illegal cluster config file found.")
-		return
-	}
-	devType := confInfo[1]
+func regOverlayRegDev(configFP string, overlay string, deviceName string, devType string, publicIP string) {
+	serverUrl := getSCCServerUrl()
+	devType, publicIP = regResolveDevice(configFP, devType, publicIP)
 	if devType == "edge" {
-		regCert(serverIP, overlay, deviceName)
-		regDevice(serverIP, overlay, deviceName, configFP)
-		// TODO Add flag or something else to replace hard code
-		exportEdgeIpsecInfo(serverIP, overlay, "10.10.70.49", deviceName)
+		// The public IP only picks the provider IP of CNF the edge connects to.
+		regCert(serverUrl, overlay, deviceName)
+		err := regDevice(serverUrl, overlay, deviceName, configFP)
+		if err != nil {
+			log.Print(err)
+		}
+		exportEdgeIpsecInfo(serverUrl, overlay, regOverlayIP(publicIP), deviceName)
 	} else if devType == "pop" || devType == "popoverlay" {
-		var publicIP []string
-		// TODO Add flag or something else to replace hard code
-		publicIP = append(publicIP, "10.10.70.39")
-		regHub(serverIP, overlay, deviceName, configFP, publicIP)
+		if publicIP == "" {
+			utils.Fatal("Public IP of pop " + deviceName + " is unknown, set it with --public-ip.")
+		}
+		err := regHub(serverUrl, overlay, deviceName, configFP, []string{publicIP})
+		if err != nil {
+			log.Print(err)
+		}
 	} else {
-		log.Fatal("Illegal device type")
+		utils.Fatal("Illegal device type " + devType)
 	}
 	regExportCapem(deviceName)
+	regSyncPeerRules(serverUrl, overlay)
+}
+
+// regResolveDevice returns device type and public IP, from flags or else from
+// the meta embedded by "sasectl init" in the exported kube config.
+func regResolveDevice(configFP string, devType string, publicIP string) (string, string) {
+	devType, publicIP, err := resolveDevice(configFP, devType, publicIP)
+	if err != nil {
+		utils.Fatal(err)
+	}
+	return devType, publicIP
+}
+
+// resolveDevice is regResolveDevice returning the error instead of exiting.
+func resolveDevice(configFP string, devType string, publicIP string) (string, string, error) {
+	meta, err := utils.LoadKubeConfigMeta(configFP)
+	if err != nil {
+		return "", "", errors.New("Failed to read device config file: " + err.Error())
+	}
+
+	if devType == "" {
+		if meta == nil || meta.Role == "" {
+			return "", "", errors.New("Device type of " + configFP + " is unknown, set it with --type.")
+		}
+		devType = meta.Role
+	} else if meta != nil && meta.Role != "" && meta.Role != devType {
+		return "", "", errors.New("Device type " + devType + " mismatches role " + meta.Role + " exported in " + configFP)
+	}
+	validType := false
+	for _, t := range utils.DeviceTypes {
+		validType = validType || t == devType
+	}
+	if !validType {
+		return "", "", errors.New("Illegal device type " + devType + ", use one of " + strings.Join(utils.DeviceTypes, "|"))
+	}
+
+	if publicIP == "" && meta != nil {
+		publicIP = meta.PublicIP
+	}
+	if publicIP != "" && net.ParseIP(publicIP) == nil {
+		return "", "", errors.New("Invalid public IP " + publicIP)
+	}
+	return devType, publicIP, nil
+}
+
+// regOverlayIP returns the provider IP of local CNF which an edge of publicIP
+// connects to.
+func regOverlayIP(publicIP string) string {
+	overlayIP, err := overlayIPOfFamily(regLocalProviderIPs(), publicIP)
+	if err != nil {
+		utils.Fatal(err)
+	}
+	return overlayIP
+}
+
+// overlayIPOfFamily returns the first of providerIPs, the first of the family
+// of publicIP if it is known, so that a dual-stack CNF is reached over IPv6 by
+// an IPv6 edge.
+func overlayIPOfFamily(providerIPs []string, publicIP string) (string, error) {
+	if len(providerIPs) == 0 {
+		return "", errors.New("No provider network found in CNF values.")
+	}
+	if publicIP == "" {
+		return providerIPs[0], nil
+	}
+	for _, ip := range providerIPs {
+		if utils.IsIPv6(ip) == utils.IsIPv6(publicIP) {
+			return ip, nil
+		}
+	}
+	return "", errors.New("CNF has no provider IP of the family of public IP " + publicIP)
+}
+
+// regLocalProviderIPs returns the addresses of local CNF on provider network.
+func regLocalProviderIPs() []string {
+	var providerIPs []string
+	cnfValueFp := filepath.Join(sasectlConf.ICNSdewanFilePath, "platform/deployment/helm/sdewan_cnf/values.yaml")
+	nfns, err := utils.LoadCNFValueFile(cnfValueFp).Nfn()
+	if err != nil {
+		utils.Fatal(err)
+	}
+	for _, nfn := range nfns {
+		if nfn.Name == "pnetwork" {
+			providerIPs = append(providerIPs, nfn.IPAddress)
+		}
+	}
+	return providerIPs
 }
 
 func regOverlayCon(overlay string, deviceName string, hubName string) {
-	serverIP := utils.CheckPodIP("scc")
-	regConUrl := "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection +
+	serverUrl := getSCCServerUrl()
+	regConUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection +
 		"/" + overlay + "/" + utils.HubCollection + "/" + hubName + "/" + utils.DeviceCollection
 
-	conName := hubName + strings.Replace(deviceName, "-", "", -1) + "conn"
-
 	regConReq := module.HubDeviceObject{
-		Metadata: module.ObjectMetaData{conName, "", "", ""},
+		Metadata: module.ObjectMetaData{Name: hubDeviceObjectName(hubName, deviceName)},
 		Specification: module.HubDeviceObjectSpec{
 			Device:        deviceName,
 			IsDelegateHub: true,
@@ -308,6 +498,11 @@ func regOverlayCon(overlay string, deviceName string, hubName string) {
 	}
 }
 
+// hubDeviceObjectName returns the name of the object connecting device to hub.
+func hubDeviceObjectName(hubName string, deviceName string) string {
+	return hubName + strings.Replace(deviceName, "-", "", -1) + "conn"
+}
+
 func createControllerObject(baseUrl string, obj module.ControllerObject, retObj module.ControllerObject) (module.ControllerObject, error) {
 	url := baseUrl
 	obj_str, _ := json.Marshal(obj)
@@ -326,8 +521,8 @@ func createControllerObject(baseUrl string, obj module.ControllerObject, retObj
 	return retObj, nil
 }
 
-func regOverlay(serverIP string, overlay string) {
-	OverlayUrl := "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection
+func regOverlay(serverUrl string, overlay string) {
+	OverlayUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection
 	overlayObj := module.OverlayObject{
 		Metadata:      module.ObjectMetaData{overlay, "", "", ""},
 		Specification: module.OverlayObjectSpec{}}
@@ -338,8 +533,8 @@ func regOverlay(serverIP string, overlay string) {
 	}
 }
 
-func regProposal(serverIP string, overlay string, proposal string) {
-	ProposalUrl := "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection +
+func regProposal(serverUrl string, overlay string, proposal string) {
+	ProposalUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection +
 		"/" + overlay + "/" + utils.ProposalCollection
 	proposalObj := module.ProposalObject{
 		Metadata:      module.ObjectMetaData{proposal, "", "", ""},
@@ -351,14 +546,15 @@ func regProposal(serverIP string, overlay string, proposal string) {
 	}
 }
 
-func regDevice(serverIP string, overlay string, deviceName string, deviceConfigFp string) {
+// regDevice registers an edge device without public IPs, so that SCC allocates
+// its overlay IP and sets up the tunnel to overlay controller.
+func regDevice(serverUrl string, overlay string, deviceName string, deviceConfigFp string) error {
 	deviceConfig, err := ioutil.ReadFile(deviceConfigFp)
 	if err != nil {
-		log.Fatal("Failed to open device config file.")
-		log.Fatal(err)
+		return errors.New("Failed to open device config file: " + err.Error())
 	}
 
-	DeviceUrl := "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection +
+	DeviceUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection +
 		"/" + overlay + "/" + utils.DeviceCollection
 	encodedDevConf := base64.StdEncoding.EncodeToString([]byte(deviceConfig))
 	certName := "device-" + deviceName + "-cert"
@@ -370,16 +566,16 @@ func regDevice(serverIP string, overlay string, deviceName string, deviceConfigF
 	if err != nil {
 		log.Print("Failed to create controller object")
 	}
+	return err
 }
 
-func regHub(serverIP string, overlay string, hubName string, hubConfigFp string, hubPublicIp []string) {
+func regHub(serverUrl string, overlay string, hubName string, hubConfigFp string, hubPublicIp []string) error {
 	hubConfig, err := ioutil.ReadFile(hubConfigFp)
 	if err != nil {
-		log.Fatal("Failed to open hub config file.")
-		log.Fatal(err)
+		return errors.New("Failed to open hub config file: " + err.Error())
 	}
 
-	HubUrl := "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection +
+	HubUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection +
 		"/" + overlay + "/" + utils.HubCollection
 	encodedHubConf := base64.StdEncoding.EncodeToString([]byte(hubConfig))
 	hubCertId := "CN=hub-" + hubName + "-cert"
@@ -391,15 +587,16 @@ func regHub(serverIP string, overlay string, hubName string, hubConfigFp string,
 	if err != nil {
 		log.Print("Failed to create controller object")
 	}
+	return err
 }
 
-func regIPRange(serverIP string, overlay string, ipRange string, ipRangeName string) {
+func regIPRange(serverUrl string, overlay string, ipRange string, ipRangeName string) error {
 	var ipRangeUrl string
 	if overlay != "" {
-		ipRangeUrl = "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection +
+		ipRangeUrl = serverUrl + "/scc/v1/" + utils.OverlayCollection +
 			"/" + overlay + "/" + utils.IPRangeCollection
 	} else {
-		ipRangeUrl = "http://" + serverIP + ":9015/scc/v1/provider/" + utils.IPRangeCollection
+		ipRangeUrl = serverUrl + "/scc/v1/provider/" + utils.IPRangeCollection
 	}
 
 	iprangeObj := module.IPRangeObject{
@@ -410,10 +607,11 @@ func regIPRange(serverIP string, overlay string, ipRange string, ipRangeName str
 	if err != nil {
 		log.Print("Failed to create controller object")
 	}
+	return err
 }
 
-func regCert(serverIP string, overlay string, deviceName string) {
-	CertUrl := "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection +
+func regCert(serverUrl string, overlay string, deviceName string) error {
+	CertUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection +
 		"/" + overlay + "/" + utils.CertCollection
 	certObj := module.CertificateObject{
 		Metadata: module.ObjectMetaData{deviceName, "", "", ""}}
@@ -422,9 +620,19 @@ func regCert(serverIP string, overlay string, deviceName string) {
 	if err != nil {
 		log.Print("Failed to create controller object")
 	}
+	return err
 }
 
-func exportEdgeIpsecInfo(serverIP string, overlay string, overlayIP string, deviceName string) {
+func exportEdgeIpsecInfo(serverUrl string, overlay string, overlayIP string, deviceName string) {
+	err := writeEdgeIpsecInfo(serverUrl, overlay, overlayIP, deviceName)
+	if err != nil {
+		utils.Fatal(err)
+	}
+}
+
+// writeEdgeIpsecInfo writes IPsec CRs of device to <device>.yaml, which are
+// applied on the edge by "sasectl register edge toController".
+func writeEdgeIpsecInfo(serverUrl string, overlay string, overlayIP string, deviceName string) error {
 	var proposalObjs []utils.ICNSdewanProposalObject
 	var proposalResource resource.ProposalResource
 	var proposals []string
@@ -433,38 +641,38 @@ func exportEdgeIpsecInfo(serverIP string, overlay string, overlayIP string, devi
 
 	cwd, err := os.Getwd()
 	if err != nil {
-		log.Fatal("Failed to get current path.")
-		log.Fatal(err)
+		return errors.New("Failed to get current path: " + err.Error())
 	}
 	outFileName := filepath.Join(cwd, deviceName+".yaml")
 
 	f, err := os.Create(outFileName)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	defer f.Close()
 	f.WriteString("---\n")
-	proposalUrl := "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection +
+	proposalUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection +
 		"/" + overlay + "/" + utils.ProposalCollection
 	checkProposal, err := utils.CallRest("GET", proposalUrl, "")
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	json.Unmarshal([]byte(checkProposal), &proposalObjs)
 
-	certUrl := "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection +
+	certUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection +
 		"/" + overlay + "/" + utils.CertCollection + "/" + deviceName
 	checkCerts, err := utils.CallRest("GET", certUrl, "")
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	json.Unmarshal([]byte(checkCerts), &certs)
 
-	deviceUrl := "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection +
+	deviceUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection +
 		"/" + overlay + "/" + utils.DeviceCollection
 
 	deviceData, err := utils.CallRest("GET", deviceUrl, "")
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	log.Println(deviceData)
 
@@ -482,14 +690,30 @@ func exportEdgeIpsecInfo(serverIP string, overlay string, overlayIP string, devi
 
 	combinedRootCA, err := base64.StdEncoding.DecodeString(certs.Data.RootCA)
 	if err != nil {
-		log.Print("Failed to decode RootCA.")
-		log.Fatal(err)
+		return errors.New("Failed to decode RootCA: " + err.Error())
 	}
 	sCombinedRootCA := strings.Split(string(combinedRootCA), "\n")
+	if len(sCombinedRootCA) < 20 {
+		return errors.New("Invalid RootCA of certificate " + deviceName)
+	}
 	// Hard code to get last 19 line of certs.
 	targetRootCA := strings.Join(sCombinedRootCA[len(sCombinedRootCA)-20:len(sCombinedRootCA)-1], "\n")
 	targetEncodedRootCA := base64.StdEncoding.EncodeToString([]byte(targetRootCA))
 
+	ipsecRes := edgeControllerIpsecHost(deviceName, overlayIP, proposals)
+	ipsecRes.PrivateCert = certs.Data.Key
+	ipsecRes.PublicCert = certs.Data.Ca
+	ipsecRes.SharedCA = targetEncodedRootCA
+
+	f.WriteString(ipsecRes.ToYaml(deviceName))
+
+	return f.Sync()
+}
+
+// edgeControllerIpsecHost returns the IPsec host from an edge to overlay
+// controller at overlayIP, without certificates. The edge requests an IPv4
+// virtual IP only, as regOverlayPreReg only accepts an IPv4 data IP range.
+func edgeControllerIpsecHost(deviceName string, overlayIP string, proposals []string) resource.IpsecResource {
 	ipsecConName := "Conn" + strings.Replace(deviceName, "-", "", -1)
 	ipsecResName := "localto" + strings.Replace(deviceName, "-", "", -1)
 	ipsecCon := resource.Connection{
@@ -500,23 +724,16 @@ func exportEdgeIpsecInfo(serverIP string, overlay string, overlayIP string, devi
 		Name:           ipsecConName,
 		LocalSourceIp:  "%config",
 	}
-	ipsecRes := resource.IpsecResource{
+	return resource.IpsecResource{
 		Name:                 ipsecResName,
 		AuthenticationMethod: "pubkey",
 		Connections:          ipsecCon,
 		CryptoProposal:       proposals,
 		ForceCryptoProposal:  "0",
 		LocalIdentifier:      "CN=device-" + deviceName + "-cert",
-		PrivateCert:          certs.Data.Key,
-		PublicCert:           certs.Data.Ca,
 		Remote:               overlayIP,
 		RemoteIdentifier:     "CN=sdewan-controller-base",
-		SharedCA:             targetEncodedRootCA,
 	}
-
-	f.WriteString(ipsecRes.ToYaml(deviceName))
-
-	f.Sync()
 }
 
 func regConfigSCCDB() {
@@ -529,13 +746,13 @@ func regConfigSCCDB() {
 	}
 	sccConfD, err := json.Marshal(sccConf)
 	if err != nil {
-		log.Fatal(err)
+		utils.Fatal(err)
 	}
 
 	err = ioutil.WriteFile(configFP, sccConfD, 0664)
 	if err != nil {
-		log.Fatal("Failed to prepare database info for scc")
-		log.Fatal(err)
+		utils.Fatal("Failed to prepare database info for scc")
+		utils.Fatal(err)
 	}
 }
 
@@ -553,69 +770,169 @@ func regCallRegCluster() {
 	output, err := regClusterCmd.CombinedOutput()
 	if err != nil {
 		log.Println(string(output))
-		log.Fatal(err)
+		utils.Fatal(err)
 	}
 	log.Println(string(output))
+	utils.AuditChange("reg_cluster " + kubeConfigFp)
 }
 
-func regSetIPRule(providerIPrange string, tableID string) {
-	cnfIP := utils.CheckPodIP("safe")
-	providerCIDR := providerIPrange + "/24"
-	cnfIfName := utils.GetIPIfName(cnfIP)
-	cmdList := []utils.CmdInfo{
-		{CmdName: "sudo", CmdArgs: []string{"ip", "rule", "add", "to", providerCIDR, "lookup", tableID}},
-		{CmdName: "sudo", CmdArgs: []string{"ip", "rule", "add", "to", "10.10.70.39/32", "lookup", tableID}},
-		{CmdName: "sudo", CmdArgs: []string{"ip", "rule", "add", "to", "10.10.70.49/32", "lookup", tableID}},
-		{CmdName: "sudo", CmdArgs: []string{"ip", "route", "add", "default", "via", cnfIP, "dev", cnfIfName, "table", tableID}},
-	}
-	for _, item := range cmdList {
-		cmd := exec.Command(item.CmdName, item.CmdArgs...)
-		output, err := cmd.CombinedOutput()
-		// log.Println(output)
+func regSetIPRule(serverUrl string, overlay string, providerIPranges []*net.IPNet, tableID int) {
+	cnfIPs, err := utils.GetPodIPs("safe")
+	if err != nil {
+		utils.Fatal(err)
+	}
+	if len(cnfIPs) == 0 {
+		utils.Fatal("Failed to get address of CNF")
+	}
+	cnfIfName := utils.GetIPIfName(cnfIPs[0])
+	if cnfIfName == "" {
+		utils.Fatal("Failed to find interface to CNF " + cnfIPs[0])
+	}
+	intent := &utils.RouteIntent{
+		Table:  tableID,
+		Device: cnfIfName,
+	}
+	for _, ipRange := range providerIPranges {
+		if ipRange.IP.To4() != nil {
+			intent.ProviderCIDR = ipRange.String()
+		} else {
+			intent.ProviderCIDR6 = ipRange.String()
+		}
+	}
+	for _, ip := range cnfIPs {
+		if utils.IsIPv6(ip) {
+			intent.Gateway6 = ip
+		} else {
+			intent.Gateway = ip
+		}
+	}
+	intent.RuleDsts = append(intent.ProviderCIDRs(), overlayPeerDsts(serverUrl, overlay)...)
+	err = utils.ApplyRouteIntent(intent)
+	if err != nil {
+		utils.Fatal(err)
+	}
+
+	// Persist the routing so that it is restored after reboot.
+	err = utils.SaveRouteIntent(utils.RouteIntentFP, intent)
+	if err != nil {
+		utils.Fatal(err)
+	}
+	err = utils.InstallRouteUnit()
+	if err != nil {
+		log.Println("Failed to install " + utils.RouteUnitName + ", routes will not survive reboot.")
+		utils.Fatal(err)
+	}
+}
+
+// regSyncPeerRules updates policy rules after hubs or devices are registered.
+func regSyncPeerRules(serverUrl string, overlay string) {
+	intent, err := utils.LoadRouteIntent(utils.RouteIntentFP)
+	if err != nil {
+		log.Println("No overlay policy routing on this host, skip updating peer rules.")
+		return
+	}
+	intent.RuleDsts = append(intent.ProviderCIDRs(), overlayPeerDsts(serverUrl, overlay)...)
+	err = utils.ApplyRouteIntent(intent)
+	if err != nil {
+		utils.Fatal(err)
+	}
+	err = utils.SaveRouteIntent(utils.RouteIntentFP, intent)
+	if err != nil {
+		utils.Fatal(err)
+	}
+}
+
+// overlayPeerDsts returns host routes to the provider addresses of local CNF,
+// and to the public IPs of hubs and devices registered in SCC.
+func overlayPeerDsts(serverUrl string, overlay string) []string {
+	peerIPs := regLocalProviderIPs()
+
+	hubs, err := queryHubs(serverUrl, overlay)
+	if err != nil {
+		utils.Fatal("Failed to query pops of overlay " + overlay)
+	}
+	for _, hub := range hubs {
+		peerIPs = append(peerIPs, hub.Specification.PublicIps...)
+	}
+	devs, err := queryDevs(serverUrl, overlay)
+	if err != nil {
+		utils.Fatal("Failed to query devices of overlay " + overlay)
+	}
+	for _, dev := range devs {
+		peerIPs = append(peerIPs, dev.Specification.PublicIps...)
+	}
+
+	var dsts []string
+	seen := make(map[string]bool)
+	for _, ip := range peerIPs {
+		dst, err := utils.HostCIDR(ip)
 		if err != nil {
-			log.Println(string(output))
-			log.Fatal(err)
+			log.Println(err)
+			continue
+		}
+		if !seen[dst] {
+			seen[dst] = true
+			dsts = append(dsts, dst)
 		}
 	}
+	return dsts
 }
 
 func regExportCapem(deviceName string) {
+	err := exportCapem(deviceName)
+	if err != nil {
+		utils.Fatal(err)
+	}
+}
+
+// exportCapem writes the CA of overlay controller to <device>ca.pem.
+func exportCapem(deviceName string) error {
 	cmd := exec.Command("kubectl", "get", "secrets", "-n", "sdewan-system", "sdewan-controller-cert-secret", "-o=jsonpath=\"{['data']['ca\\.crt']}\"")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		log.Fatal(err)
+		return errors.New("Failed to get ca.crt: " + err.Error())
 	}
 	soutput := string(output)
 	soutput = strings.Trim(soutput, "\"")
 	data, err := base64.StdEncoding.DecodeString(soutput)
 	if err != nil {
-		log.Fatal("Failed to decode ca.crt.")
-		log.Fatal(err)
+		return errors.New("Failed to decode ca.crt: " + err.Error())
 	}
 	cwd, err := os.Getwd()
 	if err != nil {
-		log.Fatal("Failed to get current path.")
-		log.Fatal(err)
+		return errors.New("Failed to get current path: " + err.Error())
 	}
 	outputFp := filepath.Join(cwd, deviceName+"ca.pem")
 
 	err = ioutil.WriteFile(outputFp, []byte(data), 0644)
 	if err != nil {
-		log.Fatal("Failed to export ca.pem for device.")
-		log.Fatal(err)
+		return errors.New("Failed to export ca.pem for device: " + err.Error())
 	}
+	return nil
 }
 
 func regCustomizeCombinedIptables() {
-	popProviderIP := "10.10.70.39"
+	cnfValueFp := filepath.Join(sasectlConf.ICNSdewanFilePath, "platform/deployment/helm/sdewan_cnf/values.yaml")
+	popProviderIP, _ := utils.LoadCNFValueFile(cnfValueFp)["popPublicIpAddress"].(string)
+	if popProviderIP == "" {
+		utils.Fatal("No popPublicIpAddress in " + cnfValueFp + ", was cluster initialized as popoverlay?")
+	}
 	safePodName := utils.CheckPodFullname("safe")
-	iptableCmd := "sudo iptables -I PREROUTING -d " + popProviderIP + "/32 -p tcp -m tcp --dport 6443 -j DNAT --to-destination 10.96.0.1:443 -t nat "
+	ruleSpec := "PREROUTING -d " + popProviderIP + "/32 -p tcp -m tcp --dport 6443 -j DNAT --to-destination 10.96.0.1:443 -t nat"
+	// The CNF entrypoint of clusters initialized or migrated as popoverlay already has the rule.
+	checkCmd := "kubectl exec -n sdewan-system " + safePodName + " -- sudo iptables -C " + ruleSpec
+	if exec.Command("bash", "-c", checkCmd).Run() == nil {
+		log.Println("DNAT of pop " + popProviderIP + " to kube API exists.")
+		return
+	}
+	iptableCmd := "sudo iptables -I " + ruleSpec
 	kubeIptableCmd := "kubectl exec -n sdewan-system " + safePodName + " -- " + iptableCmd
 
 	output, err := exec.Command("bash", "-c", kubeIptableCmd).CombinedOutput()
 	if err != nil {
 		log.Println(string(output))
-		log.Fatal(err)
+		utils.Fatal(err)
 	}
 	log.Println(string(output))
+	utils.AuditChange("cnf iptables -I " + ruleSpec)
 }
@@ -10,7 +10,6 @@ import (
 	"path/filepath"
 	"sasectl/utils"
 	"strings"
-	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -32,13 +31,14 @@ var resetCmd = &cobra.Command{
 		case "overlay", "popoverlay":
 			resetOverlayCluster()
 		default:
-			log.Fatal("Cluster didn't initialized as any role!")
+			utils.Fatal("Cluster didn't initialized as any role!")
 			return
 		}
 	},
 }
 
 func init() {
+	resetCmd.Flags().DurationVar(&rolloutTimeout, "wait-timeout", utils.RolloutTimeout, "Maximum time to wait for resources to be deleted")
 	rootCmd.AddCommand(resetCmd)
 }
 
@@ -46,6 +46,11 @@ func resetEdgeCluster() {
 	log.Println("Reset cluster role")
 	log.Println("Delete custom resources of edge cluster.")
 	edgeCleanIpsecCRsApiServer()
+	log.Println("Delete service account of overlay controller.")
+	err := utils.DeleteEdgeServiceAccount()
+	if err != nil {
+		utils.Fatal(err)
+	}
 	log.Println("Reset data plane of edge cluster.")
 	resetDataplane()
 	log.Println("Successfully reset cluster role")
@@ -64,15 +69,15 @@ func resetOverlayCluster() {
 	log.Println("Delete custom resources of overlay cluster.")
 	overlayCleanIpsecCRsRest()
 	log.Println("Delete ip rule and route for overlay.")
-	resetOverlayIPRule("40")
+	resetOverlayIPRule(configuredRouteTable())
 	resetOverlayController()
-	time.Sleep(1000)
 	resetDataplane()
 	log.Println("Successfully reset cluster role")
 }
 
 func resetOverlayController() {
 	overlayWorkingDir := filepath.Join(sasectlConf.ICNSdewanFilePath, "central-controller/deployments/kubernetes")
+	controllerObjs := overlayControllerObjects()
 
 	cmdList := []utils.CmdInfo{
 		{CmdName: "kubectl", CmdArgs: []string{"delete", "-f", "scc.yaml", "-n", "sdewan-system"}, CmdDir: overlayWorkingDir},
@@ -87,15 +92,19 @@ func resetOverlayController() {
 		output, err := cmd.CombinedOutput()
 		log.Println(string(output))
 		if err != nil {
-			log.Fatal(err)
+			utils.Fatal(err)
 			return
 		}
+		utils.AuditCmd(item)
 	}
+	log.Println("Waiting for control plane to be deleted.")
+	waitObjectsDeleted(controllerObjs)
 }
 
 func resetDataplane() {
 
 	helmWorkingDir := filepath.Join(sasectlConf.ICNSdewanFilePath, "platform/deployment/helm")
+	dataplaneObjs := dataplaneObjects()
 	cnfValueFp := filepath.Join(helmWorkingDir, "sdewan_cnf/values.yaml")
 	cmFp := filepath.Join(helmWorkingDir, "sdewan_cnf/templates/cm.yaml")
 
@@ -113,13 +122,18 @@ func resetDataplane() {
 		output, err := cmd.CombinedOutput()
 		log.Println(string(output))
 		if err != nil {
-			log.Fatal(err)
+			utils.Fatal(err)
 			return
 		}
+		utils.AuditCmd(item)
 	}
+	log.Println("Waiting for data plane to be deleted.")
+	waitObjectsDeleted(dataplaneObjs)
+
 	// Reset values.yaml for sdewan_cnf
 	cnfValue := utils.LoadCNFValueFile(cnfValueFp)
 	utils.ResetCNFValueNFN(cnfValue)
+	setPopPublicIP(cnfValue, "")
 	utils.UpdateCNFValueFile(cnfValueFp, cnfValue)
 	// Reset cm.yaml for sdewan_cnf
 	utils.GenerateCMYaml(cmFp, "reset")
@@ -127,59 +141,23 @@ func resetDataplane() {
 	utils.SetClusterRole(configFP, "", sasectlConf)
 }
 
-func resetOverlayIPRule(tableID string) {
-	listTableIDCmd := `ip route show table all | grep "table" | sed 's/.*\(table.*\)/\1/g' | awk '{print $2}' | sort | uniq`
-	ipRuleCmd := exec.Command("ip", "rule")
-	ipRouteShowCmd := exec.Command("bash", "-c", listTableIDCmd)
-	ipRouteFlushCmd := exec.Command("sudo", "ip", "route", "flush", "table", tableID)
-
-	ruleOutput, err := ipRuleCmd.CombinedOutput()
+func resetOverlayIPRule(tableID int) {
+	// Clean lookup tableID rules and routes installed by sasectl.
+	err := utils.DelPolicyRules(tableID)
 	if err != nil {
-		log.Print(string(ruleOutput))
-		log.Fatal(err)
-	}
-
-	ruleList := strings.Split(string(ruleOutput), "\n")
-	var prioList []string
-
-	// Find all lookup tableID rules.
-	for _, v := range ruleList {
-		items := strings.Fields(v)
-		if len(items) > 1 && items[len(items)-2] == "lookup" && items[len(items)-1] == tableID {
-			prio := strings.Trim(items[0], ":")
-			prioList = append(prioList, prio)
-		}
+		utils.Fatal(err)
 	}
-
-	// Clean all lookup tableID rules if existed.
-	if len(prioList) > 0 {
-		for _, prio := range prioList {
-			delRuleCmd := exec.Command("sudo", "ip", "rule", "del", "prio", prio)
-			delRuleOut, err := delRuleCmd.CombinedOutput()
-			if err != nil {
-				log.Print(string(delRuleOut))
-				log.Fatal(err)
-			}
-		}
+	err = utils.FlushPolicyRoutes(tableID)
+	if err != nil {
+		utils.Fatal(err)
 	}
-
-	ipRouteTableList, err := ipRouteShowCmd.CombinedOutput()
+	err = utils.DelLegacyPolicyRouting()
 	if err != nil {
-		log.Print(string(ipRouteTableList))
-		log.Fatal(err)
+		utils.Fatal(err)
 	}
-	tableList := strings.Split(string(ipRouteTableList), "\n")
-
-	if len(tableList) > 0 {
-		for _, tid := range tableList {
-			if tid == tableID {
-				ipFlushOut, err := ipRouteFlushCmd.CombinedOutput()
-				if err != nil {
-					log.Println(string(ipFlushOut))
-					log.Fatal(err)
-				}
-			}
-		}
+	err = utils.RemoveRouteUnit()
+	if err != nil {
+		utils.Fatal(err)
 	}
 }
 
@@ -192,7 +170,7 @@ func edgeCleanIpsecCRsApiServer() {
 	if err != nil {
 		log.Print(string(ipsecHostOutput))
 		log.Print("Failed to get custom resources of type " + IpsecHosts)
-		log.Fatal(err)
+		utils.Fatal(err)
 	}
 
 	if len(ipsecHostOutput) > 0 {
@@ -204,9 +182,10 @@ func edgeCleanIpsecCRsApiServer() {
 				if err != nil {
 					log.Print(string(delIpsecHostOutput))
 					log.Print("Failed to delete custom resource of type " + IpsecHosts)
-					log.Fatal(err)
+					utils.Fatal(err)
 				}
 				log.Println(string(delIpsecHostOutput))
+				utils.AuditChange("kubectl delete " + IpsecHosts + " " + ipsecHost)
 			}
 		}
 	}
@@ -215,7 +194,7 @@ func edgeCleanIpsecCRsApiServer() {
 	if err != nil {
 		log.Print(string(ipsecProposalOutput))
 		log.Print("Failed to get custom resources of type " + IpsecHosts)
-		log.Fatal(err)
+		utils.Fatal(err)
 	}
 
 	if len(ipsecProposalOutput) > 0 {
@@ -227,9 +206,10 @@ func edgeCleanIpsecCRsApiServer() {
 				if err != nil {
 					log.Print(string(delIpsecProposalOutput))
 					log.Print("Failed to delete custom resources of type " + IpsecHosts)
-					log.Fatal(err)
+					utils.Fatal(err)
 				}
 				log.Println(string(delIpsecProposalOutput))
+				utils.AuditChange("kubectl delete " + IpsecProposals + " " + ipsecProposal)
 			}
 		}
 	}
@@ -237,11 +217,11 @@ func edgeCleanIpsecCRsApiServer() {
 
 func overlayCleanIpsecCRsRest() {
 	//Clean Connections
-	serverIP := utils.CheckPodIP("scc")
+	serverUrl := getSCCServerUrl()
 
 	// overlays := []string{"overlay1"}
 
-	overlays, err := queryOverlays(serverIP)
+	overlays, err := queryOverlays(serverUrl)
 
 	if err != nil {
 		log.Println("Failed to query overlay info.")
@@ -250,14 +230,14 @@ func overlayCleanIpsecCRsRest() {
 	for _, overlay := range overlays {
 		// Delete registed connections and hubs.
 		o := overlay.GetMetadata().Name
-		hubs, err := queryHubs(serverIP, o)
+		hubs, err := queryHubs(serverUrl, o)
 		if err != nil {
 			log.Printf("Failed to query pops info of %s from overlay controller.", o)
 			log.Println(err)
 		}
 		for _, v := range hubs {
 			hubName := v.GetMetadata().Name
-			cons, err := queryConnections(serverIP, o, hubName)
+			cons, err := queryConnections(serverUrl, o, hubName)
 			if err != nil {
 				log.Printf("Failed to query connections from pop %s.", hubName)
 				log.Println(err)
@@ -269,68 +249,68 @@ func overlayCleanIpsecCRsRest() {
 					log.Printf("Failed to delete connections %s from pop %s overlay %s.", conName, hubName, o)
 				}
 			}
-			err = deregHub(serverIP, o, hubName)
+			err = deregHub(serverUrl, o, hubName)
 			if err != nil {
 				log.Printf("Failed to delete pop %s from overlay %s.", hubName, o)
 			}
 		}
 		// Delete registed devices.
-		devs, err := queryDevs(serverIP, o)
+		devs, err := queryDevs(serverUrl, o)
 		if err != nil {
 			log.Printf("Fatiled to query devices info of %s from overlay controller.", o)
 			log.Println(err)
 		}
 		for _, v := range devs {
 			deviceName := v.GetMetadata().Name
-			err = deregDevice(serverIP, o, deviceName)
+			err = deregDevice(serverUrl, o, deviceName)
 			if err != nil {
 				log.Printf("Failed to delete edge device %s of overlay %s.", deviceName, o)
 			}
 		}
 
 		// Delete IPRanges
-		ipranges, err := queryIPranges(serverIP, o)
+		ipranges, err := queryIPranges(serverUrl, o)
 		if err != nil {
 			log.Printf("Fatiled to query IPRange info of %s from overlay controller.", o)
 			log.Println(err)
 		}
 		for _, v := range ipranges {
 			iprangeName := v.GetMetadata().Name
-			err = deregIPRange(serverIP, o, iprangeName)
+			err = deregIPRange(serverUrl, o, iprangeName)
 			if err != nil {
 				log.Printf("Failed to delete IPRange %s of overlay %s.", iprangeName, o)
 			}
 		}
 
 		// Delete proposals
-		proposals, err := queryProposals(serverIP, o)
+		proposals, err := queryProposals(serverUrl, o)
 		if err != nil {
 			log.Printf("Fatiled to query Proposal info of %s from overlay controller.", o)
 			log.Println(err)
 		}
 		for _, v := range proposals {
 			proposalName := v.GetMetadata().Name
-			err = deregProposal(serverIP, o, proposalName)
+			err = deregProposal(serverUrl, o, proposalName)
 			if err != nil {
 				log.Printf("Failed to delete proposal %s of overlay %s.", proposalName, o)
 			}
 		}
 
 		//Delete Overlay
-		err = deregOverlay(serverIP, o)
+		err = deregOverlay(serverUrl, o)
 		if err != nil {
 			log.Printf("Failed to delete Overlay %s.", o)
 		}
 	}
 
-	providerIPranges, err := queryIPranges(serverIP, "")
+	providerIPranges, err := queryIPranges(serverUrl, "")
 	if err != nil {
 		log.Println("Fatiled to query IPRange info from overlay controller.")
 		log.Println(err)
 	}
 	for _, proIpr := range providerIPranges {
 		iprName := proIpr.GetMetadata().Name
-		err = deregIPRange(serverIP, "", iprName)
+		err = deregIPRange(serverUrl, "", iprName)
 		if err != nil {
 			log.Printf("Failed to delete provider iprange %s.", iprName)
 		}
@@ -5,14 +5,18 @@
 package cmd
 
 import (
-	"os"
 	"sasectl/utils"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
 var (
 	sasectlConf *utils.SaseCtlConf
+	sccEndpoint string
+	sccContext  string
+	restTimeout time.Duration
+	restRetries int
 )
 
 const (
@@ -24,17 +28,67 @@ var rootCmd = &cobra.Command{
 	Short: "Command line tools for Smart-Edge Open SASE EK",
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		sasectlConf, _ = utils.LoadSasectlConfig(configFP)
+		ctx, err := sasectlConf.GetContext(sccContext)
+		if err != nil {
+			utils.Fatal(err)
+		}
+		utils.SetRestContext(ctx)
+		if !cmd.Flags().Changed("request-timeout") && sasectlConf.RestTimeout != "" {
+			restTimeout, err = time.ParseDuration(sasectlConf.RestTimeout)
+			if err != nil {
+				utils.Fatal("Invalid REST-Timeout in sasectl config: " + err.Error())
+			}
+		}
+		if !cmd.Flags().Changed("retries") && sasectlConf.RestRetries != nil {
+			restRetries = *sasectlConf.RestRetries
+		}
+		utils.SetRestPolicy(restTimeout, restRetries)
+		startAudit(cmd)
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		utils.FinishAudit()
+		utils.StopSCCPortForward()
 	},
 	// Run: func(cmd *cobra.Command, args []string) {
 	// },
 }
 
 func Execute() {
+	// Commands failing through utils.Exit stop the port-forward themselves.
+	defer utils.StopSCCPortForward()
 	err := rootCmd.Execute()
 	if err != nil {
-		os.Exit(1)
+		utils.Exit(1, err.Error())
 	}
 }
 
 func init() {
+	rootCmd.PersistentFlags().StringVar(&sccContext, "context", "", "Name of the SCC context in sasectl config to use")
+	rootCmd.PersistentFlags().StringVar(&sccEndpoint, "scc-endpoint", "", "SCC REST endpoint, e.g. http://10.96.0.20:9015. Discovered from service if not set")
+	rootCmd.PersistentFlags().DurationVar(&restTimeout, "request-timeout", utils.RestDefaultTimeout, "Timeout of a single request to SCC")
+	rootCmd.PersistentFlags().IntVar(&restRetries, "retries", utils.RestDefaultRetries, "Retries of a GET request to SCC on connection errors and 5xx responses, and of other requests when SCC cannot be connected to")
+}
+
+// getSCCServerUrl resolves SCC endpoint from flag, context, config file or the SCC service.
+func getSCCServerUrl() string {
+	serverUrl, err := utils.GetSCCServerUrl(getSCCEndpoint())
+	if err != nil {
+		utils.Fatal(err)
+	}
+	return serverUrl
+}
+
+// getSCCEndpoint returns the explicitly configured SCC endpoint, if any.
+func getSCCEndpoint() string {
+	endpoint := sccEndpoint
+	if endpoint == "" && sasectlConf != nil {
+		ctx, _ := sasectlConf.GetContext(sccContext)
+		if ctx != nil {
+			endpoint = ctx.SCCEndpoint
+		}
+		if endpoint == "" {
+			endpoint = sasectlConf.SCCEndpoint
+		}
+	}
+	return endpoint
 }
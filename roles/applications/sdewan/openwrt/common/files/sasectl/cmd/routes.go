@@ -0,0 +1,125 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package cmd
+
+import (
+	"log"
+	"sasectl/utils"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	routeTable      int
+	routesApplyWait time.Duration
+)
+
+var routesCmd = &cobra.Command{
+	Use:   "routes",
+	Short: "Host policy routing managed by sasectl",
+	// Run: func(cmd *cobra.Command, args []string) {
+	// },
+}
+
+var routesApplyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Install persisted policy rules and routes, used by " + utils.RouteUnitName,
+	Run: func(cmd *cobra.Command, args []string) {
+		intent := loadRouteIntent()
+		deadline := time.Now().Add(routesApplyWait)
+		for {
+			err := utils.ApplyRouteIntent(intent)
+			if err == nil {
+				break
+			}
+			if time.Now().Add(utils.RouteApplyRetryDelay).After(deadline) {
+				utils.Fatal(err)
+			}
+			log.Printf("Failed to apply policy routing (%s), retry in %s.", err.Error(), utils.RouteApplyRetryDelay)
+			time.Sleep(utils.RouteApplyRetryDelay)
+		}
+		log.Printf("Policy routing of table %d is applied.", intent.Table)
+	},
+}
+
+var routesVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check live policy rules and routes against persisted intent",
+	Run: func(cmd *cobra.Command, args []string) {
+		intent := loadRouteIntent()
+		diffs, err := utils.VerifyRouteIntent(intent)
+		if err != nil {
+			utils.Fatal(err)
+		}
+		if len(diffs) > 0 {
+			for _, d := range diffs {
+				log.Println(d)
+			}
+			utils.Fatal("Policy routing does not match persisted intent, run \"sasectl routes apply\" to restore it.")
+		}
+		log.Printf("Policy routing of table %d matches persisted intent.", intent.Table)
+	},
+}
+
+func init() {
+	routesApplyCmd.Flags().DurationVar(&routesApplyWait, "wait", 0, "Maximum time to keep retrying if policy routing cannot be applied, e.g. while the network comes up at boot")
+	routesCmd.AddCommand(routesApplyCmd)
+	routesCmd.AddCommand(routesVerifyCmd)
+	rootCmd.AddCommand(routesCmd)
+}
+
+func loadRouteIntent() *utils.RouteIntent {
+	intent, err := utils.LoadRouteIntent(utils.RouteIntentFP)
+	if err != nil {
+		log.Println("No persisted policy routing found, was overlay pre-registered?")
+		utils.Fatal(err)
+	}
+	return intent
+}
+
+// getRouteTable returns the routing table for overlay policy routing, from
+// --route-table, sasectl config, or a newly allocated one which is saved.
+func getRouteTable() int {
+	// Entries of the shell-era sasectl would make table OverlayRouteTable look
+	// used by others.
+	err := utils.DelLegacyPolicyRouting()
+	if err != nil {
+		utils.Fatal(err)
+	}
+	table := routeTable
+	if table == 0 {
+		table = sasectlConf.RouteTable
+	}
+	if table == 0 {
+		allocated, err := utils.AllocRouteTable()
+		if err != nil {
+			utils.Fatal(err)
+		}
+		log.Printf("Allocated routing table %d for overlay policy routing.", allocated)
+		table = allocated
+	} else {
+		err = utils.CheckRouteTable(table)
+		if err != nil {
+			utils.Fatal(err)
+		}
+	}
+	if table != sasectlConf.RouteTable {
+		utils.SetRouteTable(configFP, table, sasectlConf)
+	}
+	return table
+}
+
+// configuredRouteTable returns the routing table in use, for cleanup.
+func configuredRouteTable() int {
+	intent, err := utils.LoadRouteIntent(utils.RouteIntentFP)
+	if err == nil {
+		return intent.Table
+	}
+	if sasectlConf.RouteTable != 0 {
+		return sasectlConf.RouteTable
+	}
+	return utils.OverlayRouteTable
+}
@@ -44,10 +44,10 @@ func queryControllerObjects(baseUrl string) (string, error) {
 	return res, nil
 }
 
-func queryConnections(serverIP string, overlay string, hubName string) ([]module.HubDeviceObject, error) {
-	var conObjs []module.HubDeviceObject
+func queryConnections(serverUrl string, overlay string, hubName string) ([]module.ConnectionObject, error) {
+	var conObjs []module.ConnectionObject
 
-	conUrl := "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection +
+	conUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection +
 		"/" + overlay + "/" + utils.HubCollection + "/" + hubName + "/" + utils.ConnectionCollection
 
 	res, err := queryControllerObjects(conUrl)
@@ -65,10 +65,31 @@ func queryConnections(serverIP string, overlay string, hubName string) ([]module
 	return conObjs, nil
 }
 
-func queryHubs(serverIP string, overlay string) ([]module.HubObject, error) {
+func queryDevConnections(serverUrl string, overlay string, deviceName string) ([]module.ConnectionObject, error) {
+	var conObjs []module.ConnectionObject
+
+	conUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection +
+		"/" + overlay + "/" + utils.DeviceCollection + "/" + deviceName + "/" + utils.ConnectionCollection
+
+	res, err := queryControllerObjects(conUrl)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, err
+	}
+
+	err = json.Unmarshal([]byte(res), &conObjs)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, err
+	}
+
+	return conObjs, nil
+}
+
+func queryHubs(serverUrl string, overlay string) ([]module.HubObject, error) {
 	var hubObjs []module.HubObject
 
-	hubUrl := "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection +
+	hubUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection +
 		"/" + overlay + "/" + utils.HubCollection
 
 	res, err := queryControllerObjects(hubUrl)
@@ -86,10 +107,10 @@ func queryHubs(serverIP string, overlay string) ([]module.HubObject, error) {
 	return hubObjs, nil
 }
 
-func queryDevs(serverIP string, overlay string) ([]module.DeviceObject, error) {
+func queryDevs(serverUrl string, overlay string) ([]module.DeviceObject, error) {
 	var devObjs []module.DeviceObject
 
-	devUrl := "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection +
+	devUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection +
 		"/" + overlay + "/" + utils.DeviceCollection
 
 	res, err := queryControllerObjects(devUrl)
@@ -107,10 +128,10 @@ func queryDevs(serverIP string, overlay string) ([]module.DeviceObject, error) {
 	return devObjs, nil
 }
 
-func queryCerts(serverIP string, overlay string) ([]module.CertificateObject, error) {
+func queryCerts(serverUrl string, overlay string) ([]module.CertificateObject, error) {
 	var certObjs []module.CertificateObject
 
-	certUrl := "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection +
+	certUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection +
 		"/" + overlay + "/" + utils.CertCollection
 
 	res, err := queryControllerObjects(certUrl)
@@ -128,15 +149,15 @@ func queryCerts(serverIP string, overlay string) ([]module.CertificateObject, er
 	return certObjs, nil
 }
 
-func queryIPranges(serverIP string, overlay string) ([]module.IPRangeObject, error) {
+func queryIPranges(serverUrl string, overlay string) ([]module.IPRangeObject, error) {
 	var ipRangeObjs []module.IPRangeObject
 
 	var ipRangeUrl string
 	if overlay != "" {
-		ipRangeUrl = "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection +
+		ipRangeUrl = serverUrl + "/scc/v1/" + utils.OverlayCollection +
 			"/" + overlay + "/" + utils.IPRangeCollection
 	} else {
-		ipRangeUrl = "http://" + serverIP + ":9015/scc/v1/provider/" + utils.IPRangeCollection
+		ipRangeUrl = serverUrl + "/scc/v1/provider/" + utils.IPRangeCollection
 	}
 
 	res, err := queryControllerObjects(ipRangeUrl)
@@ -154,10 +175,10 @@ func queryIPranges(serverIP string, overlay string) ([]module.IPRangeObject, err
 	return ipRangeObjs, nil
 }
 
-func queryProposals(serverIP string, overlay string) ([]module.ProposalObject, error) {
+func queryProposals(serverUrl string, overlay string) ([]module.ProposalObject, error) {
 	var proposalObjs []module.ProposalObject
 
-	proposalUrl := "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection +
+	proposalUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection +
 		"/" + overlay + "/" + utils.ProposalCollection
 
 	res, err := queryControllerObjects(proposalUrl)
@@ -175,11 +196,11 @@ func queryProposals(serverIP string, overlay string) ([]module.ProposalObject, e
 	return proposalObjs, nil
 }
 
-func queryOverlays(serverIP string) ([]module.OverlayObject, error) {
+func queryOverlays(serverUrl string) ([]module.OverlayObject, error) {
 
 	var overlayObjs []module.OverlayObject
 
-	overlayUrl := "http://" + serverIP + ":9015/scc/v1/" + utils.OverlayCollection
+	overlayUrl := serverUrl + "/scc/v1/" + utils.OverlayCollection
 
 	res, err := queryControllerObjects(overlayUrl)
 	if err != nil {
@@ -0,0 +1,242 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sasectl/utils"
+	"strings"
+
+	"github.com/akraino-edge-stack/icn-sdwan/central-controller/src/scc/pkg/module"
+	"github.com/spf13/cobra"
+)
+
+// topologyNode is a hub or device of an overlay.
+type topologyNode struct {
+	ID            string   `json:"id"`
+	Overlay       string   `json:"overlay"`
+	Kind          string   `json:"kind"`
+	Name          string   `json:"name"`
+	PublicIps     []string `json:"publicIps"`
+	CertificateId string   `json:"certificateId"`
+}
+
+// topologyEdge is a connection between two nodes.
+type topologyEdge struct {
+	Name    string `json:"name"`
+	Overlay string `json:"overlay"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	State   string `json:"state"`
+}
+
+type topology struct {
+	Overlays []string       `json:"overlays"`
+	Nodes    []topologyNode `json:"nodes"`
+	Edges    []topologyEdge `json:"edges"`
+}
+
+var topologyCmd = &cobra.Command{
+	Use:   "topology",
+	Short: "Print overlay topology as Graphviz DOT, Mermaid or JSON",
+	Run: func(cmd *cobra.Command, args []string) {
+		overlay, err := cmd.Flags().GetString("overlay")
+		if err != nil {
+			utils.Fatal(err)
+		}
+		format, err := cmd.Flags().GetString("format")
+		if err != nil {
+			utils.Fatal(err)
+		}
+
+		topo := buildTopology(getSCCServerUrl(), overlay)
+		switch format {
+		case "dot":
+			fmt.Print(topologyToDot(topo))
+		case "mermaid":
+			fmt.Print(topologyToMermaid(topo))
+		case "json":
+			data, err := json.MarshalIndent(topo, "", "  ")
+			if err != nil {
+				utils.Fatal(err)
+			}
+			fmt.Println(string(data))
+		default:
+			utils.Fatal("Unknown format " + format + ", use dot, mermaid or json")
+		}
+	},
+}
+
+func init() {
+	topologyCmd.Flags().StringP("overlay", "o", "", "Only print this overlay, all overlays if not set")
+	topologyCmd.RegisterFlagCompletionFunc("overlay", completeSCC(utils.OverlayCollection))
+	topologyCmd.Flags().StringP("format", "f", "dot", "Output format: dot, mermaid or json")
+	topologyCmd.RegisterFlagCompletionFunc("format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"dot", "mermaid", "json"}, cobra.ShellCompDirectiveNoFileComp
+	})
+	rootCmd.AddCommand(topologyCmd)
+}
+
+func topologyNodeID(overlay string, kind string, name string) string {
+	return overlay + "/" + strings.ToLower(kind) + "/" + name
+}
+
+// buildTopology walks overlays, hubs, devices and their connections on SCC.
+func buildTopology(serverUrl string, overlayFilter string) *topology {
+	topo := &topology{}
+	overlays, err := queryOverlays(serverUrl)
+	if err != nil {
+		utils.Fatal("Failed to query overlay info.")
+	}
+
+	for _, o := range overlays {
+		overlay := o.Metadata.Name
+		if overlayFilter != "" && overlay != overlayFilter {
+			continue
+		}
+		topo.Overlays = append(topo.Overlays, overlay)
+
+		hubs, err := queryHubs(serverUrl, overlay)
+		if err != nil {
+			utils.Fatal("Failed to query pops of overlay " + overlay)
+		}
+		for _, hub := range hubs {
+			topo.Nodes = append(topo.Nodes, topologyNode{
+				ID:            topologyNodeID(overlay, connEndHub, hub.Metadata.Name),
+				Overlay:       overlay,
+				Kind:          connEndHub,
+				Name:          hub.Metadata.Name,
+				PublicIps:     hub.Specification.PublicIps,
+				CertificateId: hub.Specification.CertificateId,
+			})
+		}
+
+		devs, err := queryDevs(serverUrl, overlay)
+		if err != nil {
+			utils.Fatal("Failed to query devices of overlay " + overlay)
+		}
+		for _, dev := range devs {
+			topo.Nodes = append(topo.Nodes, topologyNode{
+				ID:            topologyNodeID(overlay, connEndDevice, dev.Metadata.Name),
+				Overlay:       overlay,
+				Kind:          connEndDevice,
+				Name:          dev.Metadata.Name,
+				PublicIps:     dev.Specification.PublicIps,
+				CertificateId: dev.Specification.CertificateId,
+			})
+		}
+
+		for _, c := range listConnections(serverUrl, overlay) {
+			topo.Edges = append(topo.Edges, topologyEdgeOf(overlay, c))
+		}
+	}
+	if overlayFilter != "" && len(topo.Overlays) == 0 {
+		utils.Fatal("Overlay " + overlayFilter + " not found")
+	}
+	return topo
+}
+
+// topologyEdgeOf returns the edge of connection c between its ends.
+func topologyEdgeOf(overlay string, c module.ConnectionObject) topologyEdge {
+	end1, end2 := connEndOf(c.Info.End1), connEndOf(c.Info.End2)
+	return topologyEdge{
+		Name:    c.Metadata.Name,
+		Overlay: overlay,
+		From:    topologyNodeID(overlay, end1.kind, end1.name),
+		To:      topologyNodeID(overlay, end2.kind, end2.name),
+		State:   c.Info.State,
+	}
+}
+
+func (n *topologyNode) labelLines() []string {
+	lines := []string{n.Kind + " " + n.Name}
+	if len(n.PublicIps) > 0 {
+		lines = append(lines, strings.Join(n.PublicIps, ", "))
+	}
+	if n.CertificateId != "" {
+		lines = append(lines, n.CertificateId)
+	}
+	return lines
+}
+
+func (e *topologyEdge) label() string {
+	if e.State == "" {
+		return e.Name
+	}
+	return e.Name + " (" + e.State + ")"
+}
+
+func topologyToDot(topo *topology) string {
+	var b strings.Builder
+	b.WriteString("graph sase {\n")
+	b.WriteString("  node [shape=box];\n")
+	for i, overlay := range topo.Overlays {
+		fmt.Fprintf(&b, "  subgraph cluster_%d {\n", i)
+		fmt.Fprintf(&b, "    label=%q;\n", "overlay "+overlay)
+		for _, n := range topo.Nodes {
+			if n.Overlay != overlay {
+				continue
+			}
+			shape := "box"
+			if n.Kind == connEndHub {
+				shape = "doubleoctagon"
+			}
+			fmt.Fprintf(&b, "    %q [label=%q, shape=%s];\n", n.ID, strings.Join(n.labelLines(), "\n"), shape)
+		}
+		b.WriteString("  }\n")
+	}
+	for _, e := range topo.Edges {
+		fmt.Fprintf(&b, "  %q -- %q [label=%q];\n", e.From, e.To, e.label())
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func topologyToMermaid(topo *topology) string {
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+	for _, overlay := range topo.Overlays {
+		fmt.Fprintf(&b, "  subgraph %s[\"overlay %s\"]\n", mermaidID("overlay/"+overlay), mermaidText(overlay))
+		for _, n := range topo.Nodes {
+			if n.Overlay != overlay {
+				continue
+			}
+			var lines []string
+			for _, l := range n.labelLines() {
+				lines = append(lines, mermaidText(l))
+			}
+			left, right := "[", "]"
+			if n.Kind == connEndHub {
+				left, right = "{{", "}}"
+			}
+			fmt.Fprintf(&b, "    %s%s\"%s\"%s\n", mermaidID(n.ID), left, strings.Join(lines, "<br/>"), right)
+		}
+		b.WriteString("  end\n")
+	}
+	for _, e := range topo.Edges {
+		fmt.Fprintf(&b, "  %s ---|\"%s\"| %s\n", mermaidID(e.From), mermaidText(e.label()), mermaidID(e.To))
+	}
+	return b.String()
+}
+
+// mermaidID returns id with bytes other than letters and digits escaped as
+// "_<hex>", so that distinct ids, e.g. of "a-b" and "a_b", stay distinct.
+func mermaidID(id string) string {
+	var b strings.Builder
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "_%02x", c)
+		}
+	}
+	return b.String()
+}
+
+func mermaidText(text string) string {
+	return strings.ReplaceAll(text, "\"", "#quot;")
+}
@@ -0,0 +1,113 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/akraino-edge-stack/icn-sdwan/central-controller/src/scc/pkg/module"
+)
+
+// Connections of overlay1 as returned by SCC, with a device named like an
+// escaped name of another.
+const topologyConnectionsJSON = `[{
+	"metadata": {"name": "Hub.pop1-Device.edge-1"},
+	"information": {
+		"end1": {"name": "Hub.pop1", "type": "Hub", "ip": "10.10.70.39"},
+		"end2": {"name": "Device.edge-1", "type": "Device", "ip": "192.169.0.5"},
+		"state": "Deployed"
+	}
+}, {
+	"metadata": {"name": "Hub.pop1-Device.edge_1"},
+	"information": {
+		"end1": {"name": "Hub.pop1", "type": "Hub", "ip": "10.10.70.39"},
+		"end2": {"name": "Device.edge_1", "type": "Device", "ip": "192.169.0.6"},
+		"state": "Error"
+	}
+}]`
+
+func testTopology(t *testing.T) *topology {
+	var cons []module.ConnectionObject
+	err := json.Unmarshal([]byte(topologyConnectionsJSON), &cons)
+	if err != nil {
+		t.Fatal(err)
+	}
+	topo := &topology{
+		Overlays: []string{"overlay1"},
+		Nodes: []topologyNode{
+			{ID: "overlay1/hub/pop1", Overlay: "overlay1", Kind: connEndHub, Name: "pop1",
+				PublicIps: []string{"10.10.70.39"}, CertificateId: "CN=hub-pop1-cert"},
+			{ID: "overlay1/device/edge-1", Overlay: "overlay1", Kind: connEndDevice, Name: "edge-1"},
+			{ID: "overlay1/device/edge_1", Overlay: "overlay1", Kind: connEndDevice, Name: "edge_1"},
+		},
+	}
+	for _, c := range cons {
+		topo.Edges = append(topo.Edges, topologyEdgeOf("overlay1", c))
+	}
+	return topo
+}
+
+func TestTopologyEdgeOf(t *testing.T) {
+	topo := testTopology(t)
+	want := []topologyEdge{
+		{Name: "Hub.pop1-Device.edge-1", Overlay: "overlay1", From: "overlay1/hub/pop1", To: "overlay1/device/edge-1", State: "Deployed"},
+		{Name: "Hub.pop1-Device.edge_1", Overlay: "overlay1", From: "overlay1/hub/pop1", To: "overlay1/device/edge_1", State: "Error"},
+	}
+	if len(topo.Edges) != len(want) {
+		t.Fatalf("got %d edges, want %d", len(topo.Edges), len(want))
+	}
+	for i := range want {
+		if topo.Edges[i] != want[i] {
+			t.Errorf("edge %d = %+v, want %+v", i, topo.Edges[i], want[i])
+		}
+	}
+}
+
+func TestTopologyToMermaid(t *testing.T) {
+	want := `graph LR
+  subgraph overlay_2foverlay1["overlay overlay1"]
+    overlay1_2fhub_2fpop1{{"hub pop1<br/>10.10.70.39<br/>CN=hub-pop1-cert"}}
+    overlay1_2fdevice_2fedge_2d1["device edge-1"]
+    overlay1_2fdevice_2fedge_5f1["device edge_1"]
+  end
+  overlay1_2fhub_2fpop1 ---|"Hub.pop1-Device.edge-1 (Deployed)"| overlay1_2fdevice_2fedge_2d1
+  overlay1_2fhub_2fpop1 ---|"Hub.pop1-Device.edge_1 (Error)"| overlay1_2fdevice_2fedge_5f1
+`
+	got := topologyToMermaid(testTopology(t))
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestTopologyToDot(t *testing.T) {
+	want := `graph sase {
+  node [shape=box];
+  subgraph cluster_0 {
+    label="overlay overlay1";
+    "overlay1/hub/pop1" [label="hub pop1\n10.10.70.39\nCN=hub-pop1-cert", shape=doubleoctagon];
+    "overlay1/device/edge-1" [label="device edge-1", shape=box];
+    "overlay1/device/edge_1" [label="device edge_1", shape=box];
+  }
+  "overlay1/hub/pop1" -- "overlay1/device/edge-1" [label="Hub.pop1-Device.edge-1 (Deployed)"];
+  "overlay1/hub/pop1" -- "overlay1/device/edge_1" [label="Hub.pop1-Device.edge_1 (Error)"];
+}
+`
+	got := topologyToDot(testTopology(t))
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMermaidIDDistinct(t *testing.T) {
+	seen := make(map[string]string)
+	for _, id := range []string{"a-b", "a_b", "a.b", "a_2db", "ab"} {
+		m := mermaidID(id)
+		if prev, ok := seen[m]; ok {
+			t.Errorf("mermaidID(%q) = mermaidID(%q) = %q", id, prev, m)
+		}
+		seen[m] = id
+	}
+}
@@ -0,0 +1,239 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sasectl/utils"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/akraino-edge-stack/icn-sdwan/central-controller/src/scc/pkg/module"
+	"github.com/spf13/cobra"
+)
+
+var tunnelCmd = &cobra.Command{
+	Use:   "tunnel",
+	Short: "IPsec tunnels of the CNF",
+	// Run: func(cmd *cobra.Command, args []string) {
+	// },
+}
+
+var tunnelStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show IPsec SA state of the CNF, mapped to SCC connections",
+	Run: func(cmd *cobra.Command, args []string) {
+		overlay, err := cmd.Flags().GetString("overlay")
+		if err != nil {
+			utils.Fatal(err)
+		}
+		probe, err := cmd.Flags().GetBool("ping")
+		if err != nil {
+			utils.Fatal(err)
+		}
+		jsonOutput, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			utils.Fatal(err)
+		}
+
+		output, err := utils.CNFExec("ipsec", "statusall")
+		if err != nil {
+			log.Print(output)
+			utils.Fatal(err)
+		}
+		sas := utils.ParseIpsecStatus(output)
+		mapTunnelPeers(sas, overlay)
+		if probe {
+			probeTunnels(sas)
+		}
+
+		if jsonOutput {
+			data, err := json.MarshalIndent(sas, "", "  ")
+			if err != nil {
+				utils.Fatal(err)
+			}
+			fmt.Println(string(data))
+			return
+		}
+		printTunnelStatus(sas, probe)
+	},
+}
+
+func init() {
+	tunnelStatusCmd.Flags().StringP("overlay", "o", "overlay1", "Overlay to map SAs to connections, skipped if SCC is unreachable")
+	tunnelStatusCmd.RegisterFlagCompletionFunc("overlay", completeSCC(utils.OverlayCollection))
+	tunnelStatusCmd.Flags().Bool("ping", false, "Ping the overlay IP of peer across each established tunnel")
+	tunnelStatusCmd.Flags().Bool("json", false, "Print SAs as JSON")
+
+	tunnelCmd.AddCommand(tunnelStatusCmd)
+	rootCmd.AddCommand(tunnelCmd)
+}
+
+// mapTunnelPeers sets peer and SCC connections of SAs, matching the remote
+// identity against certificate IDs and the remote address against public IPs.
+func mapTunnelPeers(sas []utils.IpsecSA, overlay string) {
+	serverUrl, err := utils.GetSCCServerUrl(getSCCEndpoint())
+	if err != nil {
+		log.Println("SCC is not reachable, SAs are not mapped to connections: " + err.Error())
+		return
+	}
+	hubs, err := queryHubs(serverUrl, overlay)
+	if err != nil {
+		log.Println("Failed to query pops of overlay " + overlay + ", SAs are not mapped to connections.")
+		return
+	}
+	devs, err := queryDevs(serverUrl, overlay)
+	if err != nil {
+		log.Println("Failed to query devices of overlay " + overlay + ", SAs are not mapped to connections.")
+		return
+	}
+	matchTunnelPeers(sas, hubs, devs, listConnections(serverUrl, overlay))
+}
+
+// matchTunnelPeers sets peer and connections of SAs which match one of hubs
+// or devices, and leaves other SAs as they are. Connections are the ones of
+// peer, and of the local end too if it matches as well.
+func matchTunnelPeers(sas []utils.IpsecSA, hubs []module.HubObject, devs []module.DeviceObject, cons []module.ConnectionObject) {
+	for i := range sas {
+		sa := &sas[i]
+		peer := matchTunnelEnd(sa.RemoteID, sa.Remote, hubs, devs)
+		if peer == nil {
+			continue
+		}
+		self := matchTunnelEnd(sa.LocalID, sa.Local, hubs, devs)
+		sa.Peer = peer.kind + "/" + peer.name
+		for _, c := range cons {
+			end1, end2 := connEndOf(c.Info.End1), connEndOf(c.Info.End2)
+			if self != nil && !(end1 == *self && end2 == *peer || end1 == *peer && end2 == *self) {
+				continue
+			}
+			if end1 == *peer || end2 == *peer {
+				sa.Connections = append(sa.Connections, c.Metadata.Name)
+			}
+		}
+	}
+}
+
+// matchTunnelEnd returns the hub or device of certificate identity id or
+// public IP ip, or nil.
+func matchTunnelEnd(id string, ip string, hubs []module.HubObject, devs []module.DeviceObject) *connEnd {
+	var end *connEnd
+	for _, hub := range hubs {
+		if sameIdentity(id, hub.Specification.CertificateId) || containsString(hub.Specification.PublicIps, ip) {
+			end = &connEnd{kind: connEndHub, name: hub.Metadata.Name}
+		}
+	}
+	for _, dev := range devs {
+		if sameIdentity(id, dev.Specification.CertificateId) || containsString(dev.Specification.PublicIps, ip) {
+			end = &connEnd{kind: connEndDevice, name: dev.Metadata.Name}
+		}
+	}
+	return end
+}
+
+// probeTunnels pings, from the CNF, the remote host traffic selector of each
+// installed CHILD SA, sourced from the local one so traffic enters the tunnel.
+func probeTunnels(sas []utils.IpsecSA) {
+	for i := range sas {
+		sa := &sas[i]
+		if sa.State != "ESTABLISHED" {
+			continue
+		}
+		for _, c := range sa.Children {
+			if c.State != "INSTALLED" || c.RemoteTS == "" {
+				continue
+			}
+			target := utils.TSHostIP(c.RemoteTS)
+			if target == "" {
+				continue
+			}
+			args := []string{"ping", "-c", "3", "-W", "2"}
+			if source := utils.TSHostIP(c.LocalTS); source != "" {
+				args = append(args, "-I", source)
+			}
+			output, err := utils.CNFExec(append(args, target)...)
+			sa.Probe = &utils.IpsecProbeState{Target: target, OK: err == nil}
+			if err != nil {
+				lines := strings.Split(strings.TrimSpace(output), "\n")
+				sa.Probe.Detail = lines[len(lines)-1]
+			}
+			break
+		}
+		if sa.Probe == nil {
+			sa.Probe = &utils.IpsecProbeState{Detail: "no single host overlay IP of peer"}
+		}
+	}
+}
+
+func printTunnelStatus(sas []utils.IpsecSA, probe bool) {
+	if len(sas) == 0 {
+		log.Println("No IPsec SA found in CNF.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	header := "NAME\tSTATE\tREMOTE\tPEER\tCONNECTIONS\tCHILD SAS\tBYTES IN\tBYTES OUT\tREKEY"
+	if probe {
+		header += "\tPING"
+	}
+	fmt.Fprintln(w, header)
+	for _, sa := range sas {
+		var bytesIn, bytesOut int64
+		installed := 0
+		rekey := sa.Rekey
+		for _, c := range sa.Children {
+			bytesIn += c.BytesIn
+			bytesOut += c.BytesOut
+			if c.State == "INSTALLED" {
+				installed++
+			}
+			if rekey == "" {
+				rekey = c.Rekey
+			}
+		}
+		line := fmt.Sprintf("%s[%s]\t%s\t%s\t%s\t%s\t%d/%d\t%d\t%d\t%s", sa.Name, sa.ID, sa.State, sa.Remote,
+			dashIfEmpty(sa.Peer), dashIfEmpty(strings.Join(sa.Connections, ",")), installed, len(sa.Children),
+			bytesIn, bytesOut, dashIfEmpty(rekey))
+		if probe {
+			switch {
+			case sa.Probe == nil:
+				line += "\t-"
+			case sa.Probe.OK:
+				line += "\tok " + sa.Probe.Target
+			case sa.Probe.Target == "":
+				line += "\t" + sa.Probe.Detail
+			default:
+				line += "\tfailed " + sa.Probe.Target + ": " + sa.Probe.Detail
+			}
+		}
+		fmt.Fprintln(w, line)
+	}
+	w.Flush()
+}
+
+func sameIdentity(remoteID string, certID string) bool {
+	if remoteID == "" || certID == "" {
+		return false
+	}
+	return strings.TrimPrefix(remoteID, "CN=") == strings.TrimPrefix(certID, "CN=")
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
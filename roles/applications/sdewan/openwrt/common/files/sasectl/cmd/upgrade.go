@@ -0,0 +1,363 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sasectl/utils"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+type upgradeOptions struct {
+	providerIP string
+	publicIP   string
+	image      string
+	ctrlImage  string
+	dryRun     bool
+	yes        bool
+}
+
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Upgrade CNF and controller charts in place, without reset",
+	Long: `Regenerate values.yaml and cm.yaml of the CNF chart for the initialized role,
+show the diff, and helm upgrade the CNF and controller releases with their values
+preserved. Use it to change the provider or public IP of edge and pop, or the
+images, without tearing down the cluster.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := upgradeOptions{}
+		var err error
+		for _, item := range []struct {
+			flag  string
+			value *string
+		}{
+			{"providerIP", &opts.providerIP},
+			{"publicIP", &opts.publicIP},
+			{"image", &opts.image},
+			{"controller-image", &opts.ctrlImage},
+		} {
+			*item.value, err = cmd.Flags().GetString(item.flag)
+			if err != nil {
+				utils.Fatal(err)
+			}
+		}
+		opts.dryRun, err = cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			utils.Fatal(err)
+		}
+		opts.yes, err = cmd.Flags().GetBool("yes")
+		if err != nil {
+			utils.Fatal(err)
+		}
+
+		upgradeDataplane(&opts)
+	},
+}
+
+func init() {
+	upgradeCmd.Flags().String("providerIP", "", "New IP address of CNF provider network, edge and pop only")
+	upgradeCmd.Flags().String("publicIP", "", "New public IP address of CNF")
+	upgradeCmd.Flags().String("image", "", "New CNF image")
+	upgradeCmd.Flags().String("controller-image", "", "New sdewan controller image")
+	upgradeCmd.Flags().Bool("dry-run", false, "Only show the diff")
+	upgradeCmd.Flags().BoolP("yes", "y", false, "Apply without asking for confirmation")
+	upgradeCmd.Flags().DurationVar(&rolloutTimeout, "wait-timeout", utils.RolloutTimeout, "Maximum time to wait for deployments to be ready")
+	rootCmd.AddCommand(upgradeCmd)
+}
+
+func upgradeDataplane(opts *upgradeOptions) {
+	clusterRole := sasectlConf.ICNSdewanRole
+	if clusterRole == "" {
+		utils.Fatal("Cluster is not initialized, use sasectl init.")
+	}
+	helmWorkingDir := filepath.Join(sasectlConf.ICNSdewanFilePath, "platform/deployment/helm")
+	cnfValueFp := filepath.Join(helmWorkingDir, "sdewan_cnf/values.yaml")
+	cmFp := filepath.Join(helmWorkingDir, "sdewan_cnf/templates/cm.yaml")
+
+	oldProviderIPs := regLocalProviderIPs()
+	cnfValue := utils.LoadCNFValueFile(cnfValueFp)
+	nfns, err := cnfValue.Nfn()
+	if err != nil {
+		utils.Fatal(err)
+	}
+	if opts.providerIP != "" {
+		if clusterRole != "edge" && clusterRole != "pop" {
+			utils.Fatal("Provider IP of " + clusterRole + " cluster can not be changed.")
+		}
+		err := checkIP(opts.providerIP, "--providerIP")
+		if err != nil {
+			utils.Fatal(err)
+		}
+		// Only addresses of the family of the new one change on a dual-stack CNF.
+		for _, nfn := range nfns {
+			if utils.IsIPv6(nfn.IPAddress) != utils.IsIPv6(opts.providerIP) {
+				continue
+			}
+			switch nfn.Name {
+			case "pnetwork":
+				nfn.IPAddress = opts.providerIP
+			case "ovn-network":
+				ovnIP, err := parseOVNIP(opts.providerIP)
+				if err != nil {
+					utils.Fatal(err)
+				}
+				nfn.IPAddress = ovnIP
+			}
+		}
+	}
+	if opts.publicIP != "" {
+		err := checkIP(opts.publicIP, "--publicIP")
+		if err != nil {
+			utils.Fatal(err)
+		}
+		cnfValue["publicIpAddress"] = opts.publicIP
+		setAPIServiceIP6(cnfValue, opts.publicIP)
+	}
+	if opts.image != "" {
+		containers, ok := cnfValue["containers"].(map[string]interface{})
+		if !ok {
+			containers = make(map[string]interface{})
+			cnfValue["containers"] = containers
+		}
+		containers["image"] = opts.image
+	}
+
+	newValue, err := utils.MarshalCNFValue(cnfValue)
+	if err != nil {
+		utils.Fatal(err)
+	}
+	newCM, err := utils.RenderCMYaml(clusterRole)
+	if err != nil {
+		utils.Fatal(err)
+	}
+	valueDiff := upgradeDiff(cnfValueFp, "sdewan_cnf/values.yaml", newValue)
+	cmDiff := upgradeDiff(cmFp, "sdewan_cnf/templates/cm.yaml", newCM)
+	var ctrlDiff string
+	if opts.ctrlImage != "" {
+		ctrlDiff = upgradeReleaseValueDiff(sasectlConf.ICNSdewanCtrlChartName, "spec.sdewan.image", opts.ctrlImage)
+	}
+	if valueDiff == "" && cmDiff == "" {
+		log.Println("values.yaml and cm.yaml of CNF are up to date.")
+	}
+	fmt.Print(valueDiff + cmDiff + ctrlDiff)
+
+	if opts.dryRun {
+		return
+	}
+	if !opts.yes && !confirm("Upgrade releases "+sasectlConf.ICNSdewanCNFChartName+" and "+sasectlConf.ICNSdewanCtrlChartName+"?") {
+		log.Println("Upgrade is cancelled.")
+		return
+	}
+
+	var ctrlSet []string
+	if opts.ctrlImage != "" {
+		ctrlSet = append(ctrlSet, "spec.sdewan.image="+opts.ctrlImage)
+	}
+	applyDataplaneUpgrade(newValue, newCM, cmDiff != "", ctrlSet)
+	upgradeRouteIntent(oldProviderIPs, regLocalProviderIPs())
+	if opts.publicIP != "" || opts.providerIP != "" {
+		log.Println("Addresses of CNF are changed, update them in SCC by registering the device again.")
+	}
+	log.Println("Successfully upgraded data plane.")
+}
+
+// applyDataplaneUpgrade writes values.yaml and cm.yaml of CNF, upgrades CNF
+// and controller releases, and waits until data plane is ready.
+func applyDataplaneUpgrade(newValue []byte, newCM []byte, cmChanged bool, ctrlSet []string) {
+	helmWorkingDir := filepath.Join(sasectlConf.ICNSdewanFilePath, "platform/deployment/helm")
+	valueFp := filepath.Join(helmWorkingDir, "sdewan_cnf/values.yaml")
+	cmFp := filepath.Join(helmWorkingDir, "sdewan_cnf/templates/cm.yaml")
+	// The chart is packaged from its directory, so the files are written before
+	// helm runs, and restored if the CNF release is not upgraded with them.
+	backups, err := backupFiles(valueFp, cmFp)
+	if err != nil {
+		utils.Fatal(err)
+	}
+	err = ioutil.WriteFile(valueFp, newValue, 0666)
+	if err != nil {
+		utils.Fatal("Failed to export CNF value file: " + err.Error())
+	}
+	utils.AuditChange("file " + valueFp)
+	err = ioutil.WriteFile(cmFp, newCM, 0664)
+	if err != nil {
+		restoreFiles(backups)
+		utils.Fatal("Failed to generate CNF config map template: " + err.Error())
+	}
+	utils.AuditChange("file " + cmFp)
+
+	packageCmd := utils.CmdInfo{CmdName: "helm", CmdArgs: []string{"package", "sdewan_cnf"}, CmdDir: helmWorkingDir}
+	// --reuse-values keeps values given at install or earlier upgrades,
+	// values.yaml overrides them with the regenerated settings.
+	err = runUpgradeCmds([]utils.CmdInfo{
+		packageCmd,
+		{CmdName: "helm", CmdArgs: []string{"upgrade", sasectlConf.ICNSdewanCNFChartName, "./cnf-0.1.0.tgz",
+			"--reuse-values", "-f", "sdewan_cnf/values.yaml"}, CmdDir: helmWorkingDir},
+	})
+	if err != nil {
+		restoreFiles(backups)
+		// Keep the package in line with the restored chart.
+		runUpgradeCmds([]utils.CmdInfo{packageCmd})
+		utils.Fatal(err)
+	}
+
+	ctrlArgs := []string{"upgrade", sasectlConf.ICNSdewanCtrlChartName, "./controllers-0.1.0.tgz", "--reuse-values"}
+	for _, value := range ctrlSet {
+		ctrlArgs = append(ctrlArgs, "--set", value)
+	}
+	err = runUpgradeCmds([]utils.CmdInfo{{CmdName: "helm", CmdArgs: ctrlArgs, CmdDir: helmWorkingDir}})
+	if err != nil {
+		utils.Fatal(err)
+	}
+
+	// Pods are not rolled by a changed config map alone.
+	if cmChanged {
+		objs, err := utils.GetHelmReleaseObjects(sasectlConf.ICNSdewanCNFChartName)
+		if err != nil {
+			utils.Fatal(err)
+		}
+		err = utils.RestartWorkloads(objs)
+		if err != nil {
+			utils.Fatal(err)
+		}
+	}
+	log.Println("Waiting for data plane to be ready")
+	waitObjectsReady(dataplaneObjects())
+}
+
+func runUpgradeCmds(cmdList []utils.CmdInfo) error {
+	for _, item := range cmdList {
+		cmd := exec.Command(item.CmdName, item.CmdArgs...)
+		cmd.Dir = item.CmdDir
+		output, err := cmd.CombinedOutput()
+		log.Println(string(output))
+		if err != nil {
+			return err
+		}
+		utils.AuditCmd(item)
+	}
+	return nil
+}
+
+// fileBackup is the content of file fp before it is overwritten.
+type fileBackup struct {
+	fp      string
+	data    []byte
+	existed bool
+}
+
+func backupFiles(fps ...string) ([]fileBackup, error) {
+	var backups []fileBackup
+	for _, fp := range fps {
+		data, err := ioutil.ReadFile(fp)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		backups = append(backups, fileBackup{fp: fp, data: data, existed: err == nil})
+	}
+	return backups, nil
+}
+
+func restoreFiles(backups []fileBackup) {
+	for _, b := range backups {
+		var err error
+		if b.existed {
+			err = ioutil.WriteFile(b.fp, b.data, 0664)
+		} else {
+			err = os.Remove(b.fp)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			log.Println("Failed to restore " + b.fp + ": " + err.Error())
+			continue
+		}
+		log.Println("Restored " + b.fp + ".")
+		utils.AuditChange("file " + b.fp)
+	}
+}
+
+// upgradeReleaseValueDiff returns the diff of value at path of a helm release
+// against newValue.
+func upgradeReleaseValueDiff(release string, path string, newValue string) string {
+	oldValue, err := utils.GetHelmReleaseValue(release, path)
+	if err != nil {
+		log.Println(err)
+	}
+	diff, err := utils.DiffText("release "+release+" values", []byte(path+": "+oldValue+"\n"), []byte(path+": "+newValue+"\n"))
+	if err != nil {
+		utils.Fatal(err)
+	}
+	return diff
+}
+
+// upgradeDiff returns the diff of file fp, shown as name, against newData.
+func upgradeDiff(fp string, name string, newData []byte) string {
+	oldData, err := ioutil.ReadFile(fp)
+	if err != nil && !os.IsNotExist(err) {
+		utils.Fatal(err)
+	}
+	diff, err := utils.DiffText(name, oldData, newData)
+	if err != nil {
+		utils.Fatal(err)
+	}
+	return diff
+}
+
+// upgradeRouteIntent points persisted policy routing at the upgraded CNF: rules
+// to old provider addresses are replaced by the new ones, the gateway is
+// refreshed by apply.
+func upgradeRouteIntent(oldIPs []string, newIPs []string) {
+	intent, err := utils.LoadRouteIntent(utils.RouteIntentFP)
+	if err != nil {
+		return
+	}
+	removed := make(map[string]bool)
+	for _, ip := range oldIPs {
+		dst, _ := utils.HostCIDR(ip)
+		removed[dst] = true
+	}
+	var dsts []string
+	seen := make(map[string]bool)
+	for _, dst := range intent.RuleDsts {
+		if !removed[dst] && !seen[dst] {
+			seen[dst] = true
+			dsts = append(dsts, dst)
+		}
+	}
+	for _, ip := range newIPs {
+		dst, _ := utils.HostCIDR(ip)
+		if !seen[dst] {
+			seen[dst] = true
+			dsts = append(dsts, dst)
+		}
+	}
+	intent.RuleDsts = dsts
+	err = utils.ApplyRouteIntent(intent)
+	if err != nil {
+		utils.Fatal(err)
+	}
+	err = utils.SaveRouteIntent(utils.RouteIntentFP, intent)
+	if err != nil {
+		utils.Fatal(err)
+	}
+	log.Printf("Policy routing of table %d is updated.", intent.Table)
+}
+
+// stdinReader is shared by prompts, a reader per prompt may buffer the answers
+// of later ones.
+var stdinReader = bufio.NewReader(os.Stdin)
+
+func confirm(question string) bool {
+	fmt.Print(question + " [y/N] ")
+	answer, _ := stdinReader.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -5,6 +5,7 @@ go 1.17
 require (
 	github.com/akraino-edge-stack/icn-sdwan/central-controller/src/scc v0.0.0-20220517020728-9c7db912e90d
 	github.com/spf13/cobra v1.4.0
+	github.com/vishvananda/netlink v1.1.1-0.20211118161826-650dca95af54
 	gopkg.in/yaml.v3 v3.0.1
 )
 
@@ -12,4 +13,6 @@ require (
 	github.com/inconshreveable/mousetrap v1.0.0 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
+	github.com/vishvananda/netns v0.0.0-20200728191858-db3c7e526aae // indirect
+	golang.org/x/sys v0.0.0-20211216021012-1d35b9e2eb4e // indirect
 )
@@ -0,0 +1,296 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package utils
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const AuditLogFP = "/var/log/sasectl/audit.log"
+
+// Events of audit records. A command appends a started record, a changed
+// record for each object it changes, and a finished record once it returns or
+// fails through Exit. A command without it was interrupted.
+const (
+	AuditStarted  = "started"
+	AuditChanged  = "changed"
+	AuditFinished = "finished"
+)
+
+// Results of audited commands, as listed by ReadAuditLog.
+const (
+	AuditSucceeded  = "succeeded"
+	AuditFailed     = "failed"
+	AuditIncomplete = "incomplete"
+)
+
+// AuditRecord is a line of the JSON-lines audit log. Records of one command
+// invocation share the ID.
+type AuditRecord struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"`
+	User    string    `json:"user,omitempty"`
+	RunAs   string    `json:"runAs,omitempty"`
+	Context string    `json:"context,omitempty"`
+	Command string    `json:"command,omitempty"`
+	Args    []string  `json:"args,omitempty"`
+	Object  string    `json:"object,omitempty"`
+	Result  string    `json:"result,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+// AuditEntry is a command invocation merged from its audit records.
+type AuditEntry struct {
+	ID       string     `json:"id"`
+	Time     time.Time  `json:"time"`
+	Finished *time.Time `json:"finished,omitempty"`
+	User     string     `json:"user"`
+	RunAs    string     `json:"runAs,omitempty"`
+	Context  string     `json:"context,omitempty"`
+	Command  string     `json:"command"`
+	Args     []string   `json:"args,omitempty"`
+	Objects  []string   `json:"objects,omitempty"`
+	Result   string     `json:"result"`
+	Message  string     `json:"message,omitempty"`
+}
+
+var (
+	auditFP string
+	auditID string
+	// Flags whose values are credentials, except paths to files holding them.
+	secretFlagRegexp = regexp.MustCompile(`(?i)^--?[\w-]*(token|password|passwd|secret|key)[\w-]*$`)
+	fileFlagRegexp   = regexp.MustCompile(`(?i)(file|fp|path|dir)$`)
+	// Writes to SCC are recorded, reads are not.
+	auditedMethods = map[string]bool{"POST": true, "PUT": true, "PATCH": true, "DELETE": true}
+	// kubectl and helm verbs which change the cluster.
+	auditedVerbs = map[string]bool{"apply": true, "create": true, "delete": true, "patch": true,
+		"install": true, "uninstall": true, "upgrade": true, "rollback": true}
+)
+
+// StartAudit appends the started record of command with args to the audit log
+// fp. Changes recorded by AuditChange until FinishAudit are attributed to it.
+func StartAudit(fp string, command string, args []string, context string) error {
+	id := make([]byte, 8)
+	_, err := rand.Read(id)
+	if err != nil {
+		return err
+	}
+	record := AuditRecord{
+		ID:      hex.EncodeToString(id),
+		Event:   AuditStarted,
+		Context: context,
+		Command: command,
+		Args:    RedactArgs(args),
+	}
+	record.User, record.RunAs = auditUser()
+	// Other users may list the log, arguments holding credentials are redacted.
+	err = os.MkdirAll(filepath.Dir(fp), 0755)
+	if err != nil {
+		return errors.New("Failed to create audit log directory: " + err.Error())
+	}
+	err = appendAuditRecord(fp, record)
+	if err != nil {
+		return errors.New("Failed to write audit log " + fp + ": " + err.Error())
+	}
+	auditFP = fp
+	auditID = record.ID
+	return nil
+}
+
+// AuditChange records object as changed by the audited command, if any. A
+// failure to record is logged, the change itself has already been made.
+func AuditChange(object string) {
+	if auditID == "" {
+		return
+	}
+	err := appendAuditRecord(auditFP, AuditRecord{ID: auditID, Event: AuditChanged, Object: object})
+	if err != nil {
+		log.Println("Failed to write audit log " + auditFP + ": " + err.Error())
+	}
+}
+
+// AuditCmd records item as a change if it runs a mutating kubectl or helm verb.
+func AuditCmd(item CmdInfo) {
+	if (item.CmdName == "kubectl" || item.CmdName == "helm") && len(item.CmdArgs) > 0 && auditedVerbs[item.CmdArgs[0]] {
+		AuditChange(item.CmdName + " " + strings.Join(item.CmdArgs, " "))
+	}
+}
+
+// FinishAudit appends the finished record of the audited command.
+func FinishAudit() {
+	if auditID == "" {
+		return
+	}
+	err := appendAuditRecord(auditFP, AuditRecord{ID: auditID, Event: AuditFinished, Result: AuditSucceeded})
+	if err != nil {
+		log.Println("Failed to write audit log " + auditFP + ": " + err.Error())
+	}
+	auditID = ""
+}
+
+// FailAudit appends the finished record of the audited command with the
+// message it failed with. It is run from Fatal after the message is logged,
+// so it does not log itself.
+func FailAudit(msg string) {
+	if auditID == "" {
+		return
+	}
+	err := appendAuditRecord(auditFP, AuditRecord{ID: auditID, Event: AuditFinished, Result: AuditFailed, Message: msg})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to write audit log "+auditFP+": "+err.Error())
+	}
+	auditID = ""
+}
+
+// auditRest records a write request to SCC as the object path below /scc/v1,
+// with the name of the created object for a POST to a collection, once for all
+// attempts of the request.
+func auditRest(method string, reqUrl string, request string, reqErr error) {
+	if !auditedMethods[method] {
+		return
+	}
+	object := reqUrl
+	u, err := url.Parse(reqUrl)
+	if err == nil {
+		object = strings.TrimPrefix(u.Path, "/scc/v1/")
+	}
+	if method == "POST" {
+		var obj struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+		}
+		if json.Unmarshal([]byte(request), &obj) == nil && obj.Metadata.Name != "" {
+			object += "/" + obj.Metadata.Name
+		}
+	}
+	if reqErr != nil {
+		object += " (failed)"
+	}
+	AuditChange("scc " + method + " " + object)
+}
+
+func appendAuditRecord(fp string, record AuditRecord) error {
+	record.Time = time.Now()
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(fp, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// auditUser returns the login user, and the user sasectl runs as if it was
+// started through sudo.
+func auditUser() (string, string) {
+	name := "unknown"
+	u, err := user.Current()
+	if err == nil {
+		name = u.Username
+	}
+	sudoUser := os.Getenv("SUDO_USER")
+	if sudoUser != "" && sudoUser != name {
+		return sudoUser, name
+	}
+	return name, ""
+}
+
+// RedactArgs masks values of flags holding credentials, given either as
+// --flag=value or as --flag value.
+func RedactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	for i := 0; i < len(args); i++ {
+		redacted[i] = args[i]
+		flag := strings.SplitN(args[i], "=", 2)
+		if !secretFlagRegexp.MatchString(flag[0]) || fileFlagRegexp.MatchString(flag[0]) {
+			continue
+		}
+		if len(flag) == 2 {
+			redacted[i] = flag[0] + "=" + RedactedValue
+		} else if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+			i++
+			redacted[i] = RedactedValue
+		}
+	}
+	return redacted
+}
+
+// ReadAuditLog merges the records of fp into entries of command invocations,
+// in the order they were started.
+func ReadAuditLog(fp string) ([]*AuditEntry, error) {
+	f, err := os.Open(fp)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []*AuditEntry
+	byID := make(map[string]*AuditEntry)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var record AuditRecord
+		err := json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			log.Println("Skipping invalid audit record at line " + strconv.Itoa(line) + ": " + err.Error())
+			continue
+		}
+		entry := byID[record.ID]
+		if entry == nil {
+			if record.Event != AuditStarted {
+				continue
+			}
+			entry = &AuditEntry{
+				ID:      record.ID,
+				Time:    record.Time,
+				User:    record.User,
+				RunAs:   record.RunAs,
+				Context: record.Context,
+				Command: record.Command,
+				Args:    record.Args,
+				Result:  AuditIncomplete,
+			}
+			byID[record.ID] = entry
+			entries = append(entries, entry)
+			continue
+		}
+		switch record.Event {
+		case AuditChanged:
+			entry.Objects = append(entry.Objects, record.Object)
+		case AuditFinished:
+			finished := record.Time
+			entry.Finished = &finished
+			entry.Result = record.Result
+			entry.Message = record.Message
+		}
+	}
+	return entries, scanner.Err()
+}
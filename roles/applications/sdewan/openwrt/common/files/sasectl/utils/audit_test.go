@@ -0,0 +1,63 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRedactArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "no secrets",
+			args: []string{"register", "overlay", "regDev", "--name", "edge-1", "-f", "edge-1.yaml"},
+			want: []string{"register", "overlay", "regDev", "--name", "edge-1", "-f", "edge-1.yaml"},
+		},
+		{
+			name: "value after equal sign",
+			args: []string{"--token=abc", "--db-password=secret", "--api-key="},
+			want: []string{"--token=<redacted>", "--db-password=<redacted>", "--api-key=<redacted>"},
+		},
+		{
+			name: "value as next argument",
+			args: []string{"--token", "abc", "-password", "secret", "--name", "edge-1"},
+			want: []string{"--token", "<redacted>", "-password", "<redacted>", "--name", "edge-1"},
+		},
+		{
+			name: "case insensitive",
+			args: []string{"--Bearer-TOKEN", "abc", "--SECRET=x"},
+			want: []string{"--Bearer-TOKEN", "<redacted>", "--SECRET=<redacted>"},
+		},
+		{
+			name: "paths to credential files are kept",
+			args: []string{"--key-file", "/etc/sasectl/client-key.pem", "--token-file=/etc/sasectl/scc-token", "--secret-dir", "/etc/secrets"},
+			want: []string{"--key-file", "/etc/sasectl/client-key.pem", "--token-file=/etc/sasectl/scc-token", "--secret-dir", "/etc/secrets"},
+		},
+		{
+			name: "flag without value",
+			args: []string{"--token", "--name", "edge-1", "--password"},
+			want: []string{"--token", "--name", "edge-1", "--password"},
+		},
+		{
+			name: "positional argument",
+			args: []string{"describe", "token", "password"},
+			want: []string{"describe", "token", "password"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RedactArgs(tt.args)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RedactArgs(%q) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package utils
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/akraino-edge-stack/icn-sdwan/central-controller/src/scc/pkg/module"
+)
+
+// BackupFormatVersion is increased whenever the archive layout changes in a
+// way older sasectl can not restore.
+const BackupFormatVersion = 1
+
+const (
+	backupManifestName = "manifest.json"
+	backupObjectsName  = "objects.json"
+)
+
+// BackupManifest describes a backup archive.
+type BackupManifest struct {
+	Version   int       `json:"version"`
+	Created   time.Time `json:"created"`
+	SCCServer string    `json:"sccServer"`
+	Overlays  []string  `json:"overlays"`
+}
+
+// SCCBackup holds SCC objects as returned by SCC, so that no field is lost.
+type SCCBackup struct {
+	ProviderIPRanges []json.RawMessage `json:"providerIPRanges"`
+	Overlays         []OverlayBackup   `json:"overlays"`
+}
+
+type OverlayBackup struct {
+	Overlay      json.RawMessage           `json:"overlay"`
+	Proposals    []json.RawMessage         `json:"proposals"`
+	IPRanges     []json.RawMessage         `json:"ipRanges"`
+	Certificates []json.RawMessage         `json:"certificates"`
+	Hubs         []json.RawMessage         `json:"hubs"`
+	Devices      []json.RawMessage         `json:"devices"`
+	Connections  []module.ConnectionObject `json:"connections"`
+}
+
+// RawObjectName returns metadata.name of a raw SCC object.
+func RawObjectName(raw json.RawMessage) string {
+	var obj struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+	}
+	json.Unmarshal(raw, &obj)
+	return obj.Metadata.Name
+}
+
+// WriteBackupArchive writes manifest and backup into a gzipped tar archive.
+// The archive holds certificates and kube configs, so it is only readable by owner.
+// It is written to a temporary file renamed to fp, so that an existing archive
+// is not lost to a failed write.
+func WriteBackupArchive(fp string, manifest *BackupManifest, backup *SCCBackup) error {
+	f, err := ioutil.TempFile(filepath.Dir(fp), "."+filepath.Base(fp)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	err = writeBackupTar(f, manifest, backup)
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if err != nil {
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), fp)
+}
+
+func writeBackupTar(w io.Writer, manifest *BackupManifest, backup *SCCBackup) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	for _, entry := range []struct {
+		name string
+		obj  interface{}
+	}{
+		{backupManifestName, manifest},
+		{backupObjectsName, backup},
+	} {
+		data, err := json.MarshalIndent(entry.obj, "", "  ")
+		if err != nil {
+			return err
+		}
+		err = tw.WriteHeader(&tar.Header{
+			Name:    entry.name,
+			Mode:    0600,
+			Size:    int64(len(data)),
+			ModTime: manifest.Created,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		if err != nil {
+			return err
+		}
+	}
+	err := tw.Close()
+	if err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// ReadBackupArchive reads an archive written by WriteBackupArchive.
+func ReadBackupArchive(fp string) (*BackupManifest, *SCCBackup, error) {
+	f, err := os.Open(fp)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, nil, errors.New("Invalid backup archive " + fp + ": " + err.Error())
+	}
+	tr := tar.NewReader(gr)
+
+	var manifest *BackupManifest
+	var backup *SCCBackup
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, errors.New("Invalid backup archive " + fp + ": " + err.Error())
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch hdr.Name {
+		case backupManifestName:
+			manifest = &BackupManifest{}
+			err = json.Unmarshal(data, manifest)
+		case backupObjectsName:
+			backup = &SCCBackup{}
+			err = json.Unmarshal(data, backup)
+		}
+		if err != nil {
+			return nil, nil, errors.New("Failed to parse " + hdr.Name + " of " + fp + ": " + err.Error())
+		}
+	}
+
+	if manifest == nil || backup == nil {
+		return nil, nil, errors.New("Backup archive " + fp + " misses " + backupManifestName + " or " + backupObjectsName)
+	}
+	if manifest.Version > BackupFormatVersion {
+		return nil, nil, errors.New("Backup archive version " + strconv.Itoa(manifest.Version) +
+			" is newer than supported version " + strconv.Itoa(BackupFormatVersion) + ", upgrade sasectl")
+	}
+	return manifest, backup, nil
+}
@@ -0,0 +1,101 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package utils
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akraino-edge-stack/icn-sdwan/central-controller/src/scc/pkg/module"
+)
+
+func TestBackupArchiveRoundTrip(t *testing.T) {
+	manifest := &BackupManifest{
+		Version:   BackupFormatVersion,
+		Created:   time.Date(2022, 5, 17, 8, 12, 1, 0, time.UTC),
+		SCCServer: "http://10.96.0.15:9015",
+		Overlays:  []string{"overlay1"},
+	}
+	backup := &SCCBackup{
+		ProviderIPRanges: []json.RawMessage{
+			json.RawMessage(`{"metadata":{"name":"provideripr"},"spec":{"subnet":"192.168.0.0","minIp":1,"maxIp":25}}`),
+		},
+		Overlays: []OverlayBackup{{
+			Overlay:   json.RawMessage(`{"metadata":{"name":"overlay1"},"spec":{}}`),
+			Proposals: []json.RawMessage{json.RawMessage(`{"metadata":{"name":"proposal1"},"spec":{"encryption":"aes256","hash":"sha256","dhGroup":"modp4096"}}`)},
+			Devices: []json.RawMessage{
+				json.RawMessage(`{"metadata":{"name":"edge-1"},"spec":{"publicIps":[],"forceHubConnectivity":true,"kubeConfig":"YXBpVmVyc2lvbg=="}}`),
+			},
+			Connections: []module.ConnectionObject{{
+				Metadata: module.ObjectMetaData{Name: "Hub.pop1-Device.edge-1"},
+				Info: module.ConnectionInfo{
+					End1:  module.ConnectionEnd{Name: "Hub.pop1", Type: "Hub", IP: "10.10.70.39"},
+					End2:  module.ConnectionEnd{Name: "Device.edge-1", Type: "Device", IP: "192.169.0.5"},
+					State: Resource_Status_Deployed,
+				},
+			}},
+		}},
+	}
+
+	fp := filepath.Join(t.TempDir(), "scc-backup.tar.gz")
+	err := WriteBackupArchive(fp, manifest, backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("archive mode = %v, want 0600", info.Mode().Perm())
+	}
+	// Only the archive is left in the directory, the temporary file is renamed.
+	entries, err := ioutil.ReadDir(filepath.Dir(fp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d files next to archive, want 1", len(entries))
+	}
+
+	gotManifest, gotBackup, err := ReadBackupArchive(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotManifest, manifest) {
+		t.Errorf("manifest = %+v, want %+v", gotManifest, manifest)
+	}
+	// Raw objects are compared as JSON, as they are indented in the archive.
+	gotData, err := json.Marshal(gotBackup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantData, err := json.Marshal(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(gotData) != string(wantData) {
+		t.Errorf("backup = %s, want %s", gotData, wantData)
+	}
+}
+
+func TestReadBackupArchiveNewerVersion(t *testing.T) {
+	fp := filepath.Join(t.TempDir(), "scc-backup.tar.gz")
+	manifest := &BackupManifest{Version: BackupFormatVersion + 1, Created: time.Now()}
+	err := WriteBackupArchive(fp, manifest, &SCCBackup{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = ReadBackupArchive(fp)
+	if err == nil || !strings.Contains(err.Error(), "upgrade sasectl") {
+		t.Errorf("err = %v, want newer version error", err)
+	}
+}
@@ -0,0 +1,87 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package utils
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"regexp"
+	"time"
+)
+
+const RedactedValue = "<redacted>"
+
+var (
+	// Values of keys holding credentials, also with a prefix as in SCC_TOKEN, in
+	// yaml ("key: value"), json ("key": "value") and env files (KEY=value).
+	secretValueRegexp = regexp.MustCompile(`(?im)((?:^|[\s{,"])"?(?:[\w.-]*[_-])?(?:token|password|passwd|secret|key|privateKey|client-key-data|` +
+		`client-certificate-data|kubeConfig|kubeconfig|access-token|id-token|refresh-token|bearerToken)"?\s*[:=]\s*)` +
+		`("(?:[^"\\]|\\.)*"|[^\s,}]+)`)
+	pemBlockRegexp = regexp.MustCompile(`(?s)-----BEGIN ([A-Z ]*PRIVATE KEY)-----.*?-----END [A-Z ]*PRIVATE KEY-----`)
+)
+
+// RedactSecrets masks private keys, tokens, passwords and embedded kube configs,
+// so that the text can be shared for troubleshooting.
+func RedactSecrets(data string) string {
+	data = pemBlockRegexp.ReplaceAllString(data, "-----BEGIN $1-----"+RedactedValue+"-----END $1-----")
+	return secretValueRegexp.ReplaceAllStringFunc(data, func(m string) string {
+		sub := secretValueRegexp.FindStringSubmatch(m)
+		value := RedactedValue
+		if len(sub[2]) > 0 && sub[2][0] == '"' {
+			value = `"` + RedactedValue + `"`
+		}
+		return sub[1] + value
+	})
+}
+
+// SupportBundle is a gzipped tar archive of diagnostic files, all put below a
+// top directory named after the archive.
+type SupportBundle struct {
+	f       *os.File
+	gw      *gzip.Writer
+	tw      *tar.Writer
+	dir     string
+	created time.Time
+}
+
+// NewSupportBundle creates the archive fp, only readable by owner.
+func NewSupportBundle(fp string, dir string) (*SupportBundle, error) {
+	f, err := os.OpenFile(fp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	gw := gzip.NewWriter(f)
+	return &SupportBundle{f: f, gw: gw, tw: tar.NewWriter(gw), dir: dir, created: time.Now()}, nil
+}
+
+// Add writes data with secrets redacted as file name of the bundle.
+func (b *SupportBundle) Add(name string, data string) error {
+	data = RedactSecrets(data)
+	err := b.tw.WriteHeader(&tar.Header{
+		Name:    b.dir + "/" + name,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: b.created,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = b.tw.Write([]byte(data))
+	return err
+}
+
+func (b *SupportBundle) Close() error {
+	defer b.f.Close()
+	err := b.tw.Close()
+	if err != nil {
+		return err
+	}
+	err = b.gw.Close()
+	if err != nil {
+		return err
+	}
+	return b.f.Sync()
+}
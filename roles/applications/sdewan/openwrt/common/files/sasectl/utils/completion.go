@@ -0,0 +1,53 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package utils
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CompletionCacheTTL is how long names queried from SCC for shell completion are
+// reused, so that repeated tab presses don't query SCC each time.
+const CompletionCacheTTL = 30 * time.Second
+
+// CachedCompletion returns names cached under key if they are fresh, otherwise
+// names from fetch, which are cached. The cache is best effort, completion works
+// without it.
+func CachedCompletion(key string, fetch func() ([]string, error)) ([]string, error) {
+	fp := completionCachePath(key)
+	if fp != "" {
+		info, err := os.Stat(fp)
+		if err == nil && time.Since(info.ModTime()) < CompletionCacheTTL {
+			data, err := ioutil.ReadFile(fp)
+			if err == nil {
+				return strings.Fields(string(data)), nil
+			}
+		}
+	}
+
+	names, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	if fp != "" && os.MkdirAll(filepath.Dir(fp), 0700) == nil {
+		ioutil.WriteFile(fp, []byte(strings.Join(names, "\n")), 0600)
+	}
+	return names, nil
+}
+
+func completionCachePath(key string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	sum := sha1.Sum([]byte(key))
+	return filepath.Join(dir, "sasectl", "completion", hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,45 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+)
+
+// DiffText returns a unified diff of the old and new content of file name, or
+// "" if they are equal.
+func DiffText(name string, oldData []byte, newData []byte) (string, error) {
+	if bytes.Equal(oldData, newData) {
+		return "", nil
+	}
+	dir, err := ioutil.TempDir("", "sasectl-diff-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	oldFp, newFp := dir+"/old", dir+"/new"
+	err = ioutil.WriteFile(oldFp, oldData, 0600)
+	if err != nil {
+		return "", err
+	}
+	err = ioutil.WriteFile(newFp, newData, 0600)
+	if err != nil {
+		return "", err
+	}
+	output, err := exec.Command("diff", "-u", "--label", "a/"+name, "--label", "b/"+name, oldFp, newFp).Output()
+	// diff exits with 1 when files differ.
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		return string(output), nil
+	}
+	if err != nil {
+		return "", errors.New("Failed to diff " + name + ": " + err.Error())
+	}
+	return string(output), nil
+}
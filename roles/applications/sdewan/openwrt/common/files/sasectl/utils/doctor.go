@@ -0,0 +1,80 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os/exec"
+	"strings"
+)
+
+// FindIPUser returns what already uses ip: an interface of this host, a pod of
+// the cluster (primary or multus/ovn4nfv attached address), or a host answering
+// ping. Empty is returned if ip looks free.
+func FindIPUser(ip string) (string, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return "", errors.New("Invalid IP address " + ip)
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "", err
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if ipNet, ok := a.(*net.IPNet); ok && ipNet.IP.Equal(addr) {
+				return "interface " + iface.Name + " of this host", nil
+			}
+		}
+	}
+
+	output, err := exec.Command("kubectl", "get", "pod", "-A", "-o", "json").Output()
+	if err != nil {
+		return "", errors.New("Failed to list pods: " + err.Error())
+	}
+	var pods struct {
+		Items []struct {
+			Metadata struct {
+				Name        string            `json:"name"`
+				Namespace   string            `json:"namespace"`
+				Annotations map[string]string `json:"annotations"`
+			} `json:"metadata"`
+			Status struct {
+				PodIPs []struct {
+					IP string `json:"ip"`
+				} `json:"podIPs"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	err = json.Unmarshal(output, &pods)
+	if err != nil {
+		return "", err
+	}
+	for _, pod := range pods.Items {
+		user := "pod " + pod.Metadata.Namespace + "/" + pod.Metadata.Name
+		for _, podIP := range pod.Status.PodIPs {
+			if podIP.IP == ip {
+				return user, nil
+			}
+		}
+		for _, value := range pod.Metadata.Annotations {
+			if strings.Contains(value, `"`+ip+`"`) || strings.Contains(value, `"`+ip+`/`) {
+				return user, nil
+			}
+		}
+	}
+
+	if exec.Command("ping", "-c", "1", "-W", "1", ip).Run() == nil {
+		return "a host answering ping", nil
+	}
+	return "", nil
+}
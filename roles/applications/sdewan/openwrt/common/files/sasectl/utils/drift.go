@@ -0,0 +1,197 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DriftMissing  = "missing"
+	DriftExtra    = "extra"
+	DriftModified = "modified"
+)
+
+// K8sResource is a custom resource with its spec kept generic.
+type K8sResource struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+	Spec map[string]interface{} `yaml:"spec"`
+}
+
+// DriftItem is a difference between intent of SCC and resources on a cluster.
+type DriftItem struct {
+	Device string
+	Kind   string
+	Name   string
+	Drift  string
+	Detail string
+}
+
+// ParseK8sResources parses resources of kind from a multi-document yaml manifest.
+func ParseK8sResources(manifest string, kind string) ([]K8sResource, error) {
+	var res []K8sResource
+	decoder := yaml.NewDecoder(bytes.NewReader([]byte(manifest)))
+	for {
+		var r K8sResource
+		err := decoder.Decode(&r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if r.Kind == kind {
+			res = append(res, r)
+		}
+	}
+	return res, nil
+}
+
+// GetK8sResources lists resources of resourceType in all namespaces of the
+// cluster kubeConfigFp points to.
+func GetK8sResources(kubeConfigFp string, resourceType string) ([]K8sResource, error) {
+	getCmd := exec.Command("kubectl", "--kubeconfig", kubeConfigFp, "get", resourceType, "-A", "-o", "yaml")
+	var stderr bytes.Buffer
+	getCmd.Stderr = &stderr
+	output, err := getCmd.Output()
+	if err != nil {
+		return nil, errors.New("Failed to get " + resourceType + ": " + stderr.String())
+	}
+	var list struct {
+		Items []K8sResource `yaml:"items"`
+	}
+	err = yaml.Unmarshal(output, &list)
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// CompareK8sResources matches expected and actual resources by key, and reports
+// resources which are missing, extra, or whose spec differs in a field set in
+// expected. Fields only set in actual, e.g. defaults, are ignored.
+func CompareK8sResources(device string, kind string, expected []K8sResource, actual []K8sResource,
+	key func(r *K8sResource) string) []DriftItem {
+	var items []DriftItem
+	actualByKey := make(map[string]*K8sResource)
+	for i := range actual {
+		actualByKey[key(&actual[i])] = &actual[i]
+	}
+
+	for i := range expected {
+		exp := &expected[i]
+		k := key(exp)
+		act, ok := actualByKey[k]
+		if !ok {
+			items = append(items, DriftItem{Device: device, Kind: kind, Name: k, Drift: DriftMissing})
+			continue
+		}
+		delete(actualByKey, k)
+
+		var fields []string
+		for field, value := range exp.Spec {
+			fields = append(fields, specDiff(field, value, act.Spec[field])...)
+		}
+		sort.Strings(fields)
+		for _, f := range fields {
+			items = append(items, DriftItem{Device: device, Kind: kind, Name: k, Drift: DriftModified, Detail: f})
+		}
+	}
+
+	for k, act := range actualByKey {
+		items = append(items, DriftItem{Device: device, Kind: kind, Name: k, Drift: DriftExtra,
+			Detail: act.Metadata.Namespace + "/" + act.Metadata.Name})
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Name < items[j].Name
+	})
+	return items
+}
+
+// specDiff compares value of a spec field set in expected with actual. Scalars
+// are compared as strings, as a CR may carry "30" where 30 is expected, lists
+// of scalars regardless of order, and maps by the keys set in expected only.
+func specDiff(field string, expected interface{}, actual interface{}) []string {
+	if isZeroValue(expected) {
+		return nil
+	}
+	mismatch := []string{fmt.Sprintf("%s: %v, expected %v", field, actual, expected)}
+
+	switch exp := expected.(type) {
+	case map[string]interface{}:
+		act, ok := actual.(map[string]interface{})
+		if !ok {
+			return mismatch
+		}
+		var diffs []string
+		for k, v := range exp {
+			diffs = append(diffs, specDiff(field+"."+k, v, act[k])...)
+		}
+		return diffs
+	case []interface{}:
+		act, ok := actual.([]interface{})
+		if !ok || len(act) != len(exp) {
+			return mismatch
+		}
+		if scalars(exp) && scalars(act) {
+			if !reflect.DeepEqual(sortedStrings(exp), sortedStrings(act)) {
+				return mismatch
+			}
+			return nil
+		}
+		var diffs []string
+		for i := range exp {
+			diffs = append(diffs, specDiff(fmt.Sprintf("%s[%d]", field, i), exp[i], act[i])...)
+		}
+		return diffs
+	}
+	if actual == nil || fmt.Sprint(expected) != fmt.Sprint(actual) {
+		return mismatch
+	}
+	return nil
+}
+
+func scalars(values []interface{}) bool {
+	for _, v := range values {
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			return false
+		}
+	}
+	return true
+}
+
+func sortedStrings(values []interface{}) []string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = fmt.Sprint(v)
+	}
+	sort.Strings(s)
+	return s
+}
+
+func isZeroValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+// An IpsecHost as generated by sasectl, with empty certificates and mark.
+const expectedIpsecHostYaml = `apiVersion: batch.sdewan.akraino.org/v1alpha1
+kind: IpsecHost
+metadata:
+  name: localtoedge1
+  namespace: default
+spec:
+  type:
+  remote: '10.10.70.49'
+  authentication_method: pubkey
+  force_crypto_proposal: "0"
+  crypto_proposal: [proposal1,proposal2]
+  connections:
+  - name: Connedge1
+    conn_type: tunnel
+    mode: start
+    mark: ""
+    local_updown: /usr/lib/ipsec/_updown iptables
+    local_sourceip: '%config'
+    crypto_proposal: [proposal1,proposal2]
+  local_public_cert:
+  local_identifier: CN=device-edge-1-cert
+`
+
+func TestCompareK8sResources(t *testing.T) {
+	tests := []struct {
+		name   string
+		actual string
+		want   []DriftItem
+	}{
+		{
+			name: "defaults and order",
+			actual: `kind: IpsecHost
+metadata:
+  name: localtoedge1
+  namespace: default
+spec:
+  remote: 10.10.70.49
+  authentication_method: pubkey
+  force_crypto_proposal: 0
+  crypto_proposal: [proposal2, proposal1]
+  connections:
+  - name: Connedge1
+    conn_type: tunnel
+    mode: start
+    mark: "0"
+    local_updown: /usr/lib/ipsec/_updown iptables
+    local_sourceip: '%config'
+    crypto_proposal: [proposal2, proposal1]
+    remote_sourceip: ""
+  local_public_cert: cert
+  local_identifier: CN=device-edge-1-cert
+`,
+		},
+		{
+			name: "modified",
+			actual: `kind: IpsecHost
+metadata:
+  name: localtoedge1
+  namespace: default
+spec:
+  remote: 10.10.70.50
+  authentication_method: pubkey
+  force_crypto_proposal: "0"
+  crypto_proposal: [proposal1]
+  connections:
+  - name: Connedge1
+    conn_type: tunnel
+    mode: start
+    local_updown: /usr/lib/ipsec/_updown iptables
+    crypto_proposal: [proposal1, proposal2]
+  local_identifier: CN=device-edge-1-cert
+`,
+			want: []DriftItem{
+				{Device: "edge-1", Kind: "IpsecHost", Name: "localtoedge1", Drift: DriftModified,
+					Detail: "connections[0].local_sourceip: <nil>, expected %config"},
+				{Device: "edge-1", Kind: "IpsecHost", Name: "localtoedge1", Drift: DriftModified,
+					Detail: "crypto_proposal: [proposal1], expected [proposal1 proposal2]"},
+				{Device: "edge-1", Kind: "IpsecHost", Name: "localtoedge1", Drift: DriftModified,
+					Detail: "remote: 10.10.70.50, expected 10.10.70.49"},
+			},
+		},
+		{
+			name: "missing and extra",
+			actual: `kind: IpsecHost
+metadata:
+  name: pop1edge1
+  namespace: default
+spec:
+  remote: 10.10.70.39
+`,
+			want: []DriftItem{
+				{Device: "edge-1", Kind: "IpsecHost", Name: "localtoedge1", Drift: DriftMissing},
+				{Device: "edge-1", Kind: "IpsecHost", Name: "pop1edge1", Drift: DriftExtra, Detail: "default/pop1edge1"},
+			},
+		},
+	}
+
+	expected, err := ParseK8sResources(expectedIpsecHostYaml, "IpsecHost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := func(r *K8sResource) string { return r.Metadata.Name }
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ParseK8sResources(tt.actual, "IpsecHost")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := CompareK8sResources("edge-1", "IpsecHost", expected, actual, key)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
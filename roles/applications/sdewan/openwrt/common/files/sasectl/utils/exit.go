@@ -0,0 +1,36 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package utils
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+// Fatal logs v like log.Fatal and exits through Exit. log.Fatal and os.Exit
+// skip deferred functions and PersistentPostRun, so sasectl must not call them.
+func Fatal(v ...interface{}) {
+	msg := fmt.Sprint(v...)
+	log.Output(2, msg)
+	Exit(1, msg)
+}
+
+// Fatalf logs like log.Fatalf and exits through Exit.
+func Fatalf(format string, v ...interface{}) {
+	msg := fmt.Sprintf(format, v...)
+	log.Output(2, msg)
+	Exit(1, msg)
+}
+
+// Exit records the audited command as failed with msg if code is not 0, stops
+// the SCC port-forward, and exits with code.
+func Exit(code int, msg string) {
+	if code != 0 {
+		FailAudit(msg)
+	}
+	StopSCCPortForward()
+	os.Exit(code)
+}
@@ -0,0 +1,182 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// InitAnswers are the settings of sasectl init, written by the interactive
+// wizard so that init can be repeated from the file.
+type InitAnswers struct {
+	Role       string `yaml:"role"`
+	ProviderIP string `yaml:"providerIP,omitempty"`
+	PublicIP   string `yaml:"publicIP,omitempty"`
+	OVNSubnet  string `yaml:"ovnSubnet,omitempty"`
+	// PopProviderIP is the address pop tunnels terminate on in popoverlay.
+	PopProviderIP string `yaml:"popProviderIP,omitempty"`
+	// ProviderIP6 is the extra IPv6 provider address of a dual-stack CNF.
+	ProviderIP6 string `yaml:"providerIP6,omitempty"`
+}
+
+// OVNNetwork is a provider or OVN network of default-networks.yaml.
+type OVNNetwork struct {
+	Kind    string
+	Name    string
+	Subnet  *net.IPNet
+	Gateway net.IP
+	// ExcludeIPs is a range "first..last" not assigned to pods.
+	ExcludeIPs string
+	// Interface is the host interface of a provider network.
+	Interface string
+}
+
+// HostAddress is an address of a host interface.
+type HostAddress struct {
+	Interface string
+	Address   *net.IPNet
+}
+
+type ovnNetworkManifest struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name string `yaml:"name"`
+	} `yaml:"metadata"`
+	Spec struct {
+		IPv4Subnets []ovnSubnetManifest `yaml:"ipv4Subnets"`
+		IPv6Subnets []ovnSubnetManifest `yaml:"ipv6Subnets"`
+		Vlan        struct {
+			ProviderInterfaceName string `yaml:"providerInterfaceName"`
+		} `yaml:"vlan"`
+	} `yaml:"spec"`
+}
+
+type ovnSubnetManifest struct {
+	Subnet     string `yaml:"subnet"`
+	Gateway    string `yaml:"gateway"`
+	ExcludeIps string `yaml:"excludeIps"`
+}
+
+func LoadInitAnswers(fp string) (*InitAnswers, error) {
+	data, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return nil, err
+	}
+	var answers InitAnswers
+	err = yaml.Unmarshal(data, &answers)
+	if err != nil {
+		return nil, errors.New("Invalid init answers " + fp + ": " + err.Error())
+	}
+	return &answers, nil
+}
+
+func SaveInitAnswers(fp string, answers *InitAnswers) error {
+	data, err := yaml.Marshal(answers)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fp, data, 0644)
+}
+
+// LoadOVNNetworks reads ProviderNetwork and Network subnets of ovn4nfv from fp,
+// the default-networks.yaml generated by the sdewan ansible role.
+func LoadOVNNetworks(fp string) ([]OVNNetwork, error) {
+	data, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return nil, err
+	}
+	var networks []OVNNetwork
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var m ovnNetworkManifest
+		err := decoder.Decode(&m)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.New("Invalid network manifest " + fp + ": " + err.Error())
+		}
+		if m.Kind != "ProviderNetwork" && m.Kind != "Network" {
+			continue
+		}
+		subnets := append(m.Spec.IPv4Subnets, m.Spec.IPv6Subnets...)
+		for _, s := range subnets {
+			_, subnet, err := net.ParseCIDR(s.Subnet)
+			if err != nil {
+				return nil, errors.New("Invalid subnet of network " + m.Metadata.Name + ": " + err.Error())
+			}
+			// Addresses are rendered with prefix length by the role.
+			bounds := strings.SplitN(s.ExcludeIps, "..", 2)
+			for i := range bounds {
+				bounds[i] = stripPrefixLen(bounds[i])
+			}
+			networks = append(networks, OVNNetwork{
+				Kind:       m.Kind,
+				Name:       m.Metadata.Name,
+				Subnet:     subnet,
+				Gateway:    net.ParseIP(stripPrefixLen(s.Gateway)),
+				ExcludeIPs: strings.Join(bounds, ".."),
+				Interface:  m.Spec.Vlan.ProviderInterfaceName,
+			})
+		}
+	}
+	return networks, nil
+}
+
+// Excludes returns whether ip is the gateway of n, or in its excluded range.
+func (n *OVNNetwork) Excludes(ip net.IP) bool {
+	if n.Gateway != nil && n.Gateway.Equal(ip) {
+		return true
+	}
+	bounds := strings.SplitN(n.ExcludeIPs, "..", 2)
+	if len(bounds) != 2 {
+		return false
+	}
+	first := net.ParseIP(bounds[0])
+	last := net.ParseIP(bounds[1])
+	if !SameIPFamily(first, ip) || !SameIPFamily(last, ip) {
+		return false
+	}
+	ip = ip.To16()
+	return bytes.Compare(ip, first.To16()) >= 0 && bytes.Compare(ip, last.To16()) <= 0
+}
+
+func stripPrefixLen(addr string) string {
+	return strings.SplitN(strings.TrimSpace(addr), "/", 2)[0]
+}
+
+// HostAddresses lists IPv4 and global IPv6 addresses of host interfaces which
+// are up, except loopback.
+func HostAddresses() ([]HostAddress, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	var addrs []HostAddress
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		ifAddrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range ifAddrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.To4() == nil && !ipNet.IP.IsGlobalUnicast() {
+				continue
+			}
+			addrs = append(addrs, HostAddress{Interface: iface.Name, Address: ipNet})
+		}
+	}
+	return addrs, nil
+}
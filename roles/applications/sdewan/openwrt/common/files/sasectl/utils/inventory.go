@@ -0,0 +1,180 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package utils
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	InventoryDone   = "done"
+	InventoryFailed = "failed"
+)
+
+// InventoryDevice is a device or pop to register on overlay controller.
+type InventoryDevice struct {
+	Name     string `yaml:"name"`
+	File     string `yaml:"file"`
+	Type     string `yaml:"type,omitempty"`
+	PublicIP string `yaml:"publicIP,omitempty"`
+	Overlay  string `yaml:"overlay,omitempty"`
+	// Pops an edge connects to.
+	Pops []string `yaml:"pops,omitempty"`
+}
+
+// InventoryState records results of an inventory registration, so that a
+// retry skips devices which are done.
+type InventoryState struct {
+	Devices map[string]InventoryResult `json:"devices"`
+}
+
+type InventoryResult struct {
+	Status  string    `json:"status"`
+	Error   string    `json:"error,omitempty"`
+	Updated time.Time `json:"updated"`
+}
+
+// LoadInventory reads devices from a csv file with header
+// "name,file,type,public-ip,overlay,pops", pops separated by ";", or from a
+// yaml file with a "devices" list. Relative files are resolved against the
+// directory of the inventory.
+func LoadInventory(fp string) ([]InventoryDevice, error) {
+	var devices []InventoryDevice
+	var err error
+	switch strings.ToLower(filepath.Ext(fp)) {
+	case ".csv":
+		devices, err = loadCSVInventory(fp)
+	case ".yaml", ".yml":
+		devices, err = loadYamlInventory(fp)
+	default:
+		return nil, errors.New("Unknown inventory format of " + fp + ", use .csv, .yaml or .yml")
+	}
+	if err != nil {
+		return nil, errors.New("Invalid inventory " + fp + ": " + err.Error())
+	}
+
+	seen := make(map[string]bool)
+	for i := range devices {
+		d := &devices[i]
+		if d.Name == "" || d.File == "" {
+			return nil, errors.New("Invalid inventory " + fp + ": name and file are required, entry " + strconv.Itoa(i+1))
+		}
+		if seen[d.Name] {
+			return nil, errors.New("Invalid inventory " + fp + ": duplicated device " + d.Name)
+		}
+		seen[d.Name] = true
+		if !filepath.IsAbs(d.File) {
+			d.File = filepath.Join(filepath.Dir(fp), d.File)
+		}
+	}
+	return devices, nil
+}
+
+func loadYamlInventory(fp string) ([]InventoryDevice, error) {
+	data, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return nil, err
+	}
+	var inventory struct {
+		Devices []InventoryDevice `yaml:"devices"`
+	}
+	err = yaml.Unmarshal(data, &inventory)
+	if err != nil {
+		return nil, err
+	}
+	return inventory.Devices, nil
+}
+
+func loadCSVInventory(fp string) ([]InventoryDevice, error) {
+	f, err := os.Open(fp)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var devices []InventoryDevice
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		d := InventoryDevice{
+			Name:     field(record, "name"),
+			File:     field(record, "file"),
+			Type:     field(record, "type"),
+			PublicIP: field(record, "public-ip"),
+			Overlay:  field(record, "overlay"),
+		}
+		for _, pop := range strings.Split(field(record, "pops"), ";") {
+			if pop = strings.TrimSpace(pop); pop != "" {
+				d.Pops = append(d.Pops, pop)
+			}
+		}
+		devices = append(devices, d)
+	}
+	return devices, nil
+}
+
+// LoadInventoryState reads the state file fp, an empty state if it does not exist.
+func LoadInventoryState(fp string) (*InventoryState, error) {
+	state := &InventoryState{Devices: make(map[string]InventoryResult)}
+	data, err := ioutil.ReadFile(fp)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, state)
+	if err != nil {
+		return nil, errors.New("Invalid inventory state " + fp + ": " + err.Error())
+	}
+	if state.Devices == nil {
+		state.Devices = make(map[string]InventoryResult)
+	}
+	return state, nil
+}
+
+func SaveInventoryState(fp string, state *InventoryState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fp, data, 0644)
+}
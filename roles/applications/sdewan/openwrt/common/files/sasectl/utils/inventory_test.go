@@ -0,0 +1,120 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package utils
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadInventory(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []InventoryDevice
+		wantErr string
+	}{
+		{
+			name: "csv",
+			file: "inventory.csv",
+			content: `name,file,type,public-ip,overlay,pops
+# comment
+edge-1, edge-1.yaml, edge, , overlay1, pop1;pop2
+pop1,/etc/pop1.yaml,pop,10.10.70.39,,
+`,
+			want: []InventoryDevice{
+				{Name: "edge-1", File: "edge-1.yaml", Type: "edge", Overlay: "overlay1", Pops: []string{"pop1", "pop2"}},
+				{Name: "pop1", File: "/etc/pop1.yaml", Type: "pop", PublicIP: "10.10.70.39"},
+			},
+		},
+		{
+			name: "yaml",
+			file: "inventory.yaml",
+			content: `devices:
+- name: edge-1
+  file: edge-1.yaml
+  pops: [pop1]
+- name: pop1
+  file: /etc/pop1.yaml
+  type: pop
+  publicIP: 10.10.70.39
+`,
+			want: []InventoryDevice{
+				{Name: "edge-1", File: "edge-1.yaml", Pops: []string{"pop1"}},
+				{Name: "pop1", File: "/etc/pop1.yaml", Type: "pop", PublicIP: "10.10.70.39"},
+			},
+		},
+		{
+			name:    "csv duplicated name",
+			file:    "inventory.csv",
+			content: "name,file\nedge-1,a.yaml\nedge-1,b.yaml\n",
+			wantErr: "duplicated device edge-1",
+		},
+		{
+			name:    "yaml duplicated name",
+			file:    "inventory.yml",
+			content: "devices:\n- {name: pop1, file: a.yaml}\n- {name: pop1, file: b.yaml}\n",
+			wantErr: "duplicated device pop1",
+		},
+		{
+			name:    "csv missing name",
+			file:    "inventory.csv",
+			content: "name,file\nedge-1,a.yaml\n,b.yaml\n",
+			wantErr: "name and file are required, entry 2",
+		},
+		{
+			name:    "csv missing file column",
+			file:    "inventory.csv",
+			content: "name,type\nedge-1,edge\n",
+			wantErr: "name and file are required, entry 1",
+		},
+		{
+			name:    "yaml missing file",
+			file:    "inventory.yaml",
+			content: "devices:\n- name: edge-1\n",
+			wantErr: "name and file are required, entry 1",
+		},
+		{
+			name:    "unknown format",
+			file:    "inventory.txt",
+			content: "edge-1 a.yaml\n",
+			wantErr: "Unknown inventory format",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			fp := filepath.Join(dir, tt.file)
+			err := ioutil.WriteFile(fp, []byte(tt.content), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := LoadInventory(fp)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Relative files are resolved against the inventory directory.
+			for i := range tt.want {
+				if !filepath.IsAbs(tt.want[i].File) {
+					tt.want[i].File = filepath.Join(dir, tt.want[i].File)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
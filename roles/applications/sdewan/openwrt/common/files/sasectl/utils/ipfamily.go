@@ -0,0 +1,120 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package utils
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Prefix lengths of an IP range given without one, as the /24 provider and data
+// ranges of preReg always were.
+const (
+	DefaultIPRangePrefix  = 24
+	DefaultIPRangePrefix6 = 64
+)
+
+// IsIPv6 returns whether ip is an IPv6 address, false if it is not an address.
+func IsIPv6(ip string) bool {
+	addr := net.ParseIP(ip)
+	return addr != nil && addr.To4() == nil
+}
+
+// IPFamily returns "IPv4" or "IPv6", for messages.
+func IPFamily(ip net.IP) string {
+	if ip.To4() != nil {
+		return "IPv4"
+	}
+	return "IPv6"
+}
+
+// SameIPFamily returns whether a and b are addresses of the same family.
+func SameIPFamily(a net.IP, b net.IP) bool {
+	return a != nil && b != nil && (a.To4() == nil) == (b.To4() == nil)
+}
+
+// ParseIPRange returns the network of an IP range given as CIDR, or as an
+// address with a default prefix length of /24 for IPv4 and /64 for IPv6.
+func ParseIPRange(ipRange string) (*net.IPNet, error) {
+	ipRange = strings.TrimSpace(ipRange)
+	if !strings.Contains(ipRange, "/") {
+		addr := net.ParseIP(ipRange)
+		if addr == nil {
+			return nil, errors.New("Invalid IP range " + ipRange)
+		}
+		prefix := DefaultIPRangePrefix
+		if addr.To4() == nil {
+			prefix = DefaultIPRangePrefix6
+		}
+		ipRange += "/" + strconv.Itoa(prefix)
+	}
+	_, ipNet, err := net.ParseCIDR(ipRange)
+	if err != nil {
+		return nil, errors.New("Invalid IP range " + ipRange)
+	}
+	return ipNet, nil
+}
+
+// CheckSCCIPRange fails if SCC cannot allocate addresses of ipRange. SCC only
+// takes IPv4 subnets and hands out the last octet, so the range must be a /24.
+func CheckSCCIPRange(ipRange *net.IPNet) error {
+	if ipRange.IP.To4() == nil {
+		return errors.New("IP range " + ipRange.String() + " is IPv6, SCC only allocates IPv4 addresses")
+	}
+	ones, _ := ipRange.Mask.Size()
+	if ones != DefaultIPRangePrefix {
+		return errors.New("IP range " + ipRange.String() + " must be a /" + strconv.Itoa(DefaultIPRangePrefix) + " for SCC")
+	}
+	return nil
+}
+
+// SplitIPRanges parses a comma separated list of IP ranges with at most one
+// range of each family, as given for dual-stack.
+func SplitIPRanges(ipRanges string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, ipRange := range strings.Split(ipRanges, ",") {
+		if strings.TrimSpace(ipRange) == "" {
+			continue
+		}
+		ipNet, err := ParseIPRange(ipRange)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range nets {
+			if SameIPFamily(n.IP, ipNet.IP) {
+				return nil, errors.New("More than one " + IPFamily(ipNet.IP) + " range in " + ipRanges)
+			}
+		}
+		nets = append(nets, ipNet)
+	}
+	if len(nets) == 0 {
+		return nil, errors.New("No IP range given")
+	}
+	return nets, nil
+}
+
+// HostInNetwork returns the address of network with the host bits of host,
+// e.g. 172.16.70.20 for network 172.16.70.0/24 and host 10.10.70.20.
+func HostInNetwork(network *net.IPNet, host net.IP) (net.IP, error) {
+	if !SameIPFamily(network.IP, host) {
+		return nil, errors.New("Address " + host.String() + " is not of the family of " + network.String())
+	}
+	prefix := network.IP.To16()
+	hostIP := host.To16()
+	// An IPv4 mask covers the last 4 bytes of the 16 byte form.
+	mask := network.Mask
+	offset := len(prefix) - len(mask)
+	addr := make(net.IP, len(prefix))
+	copy(addr, prefix)
+	for i := range mask {
+		addr[offset+i] = prefix[offset+i]&mask[i] | hostIP[offset+i]&^mask[i]
+	}
+	if network.IP.To4() != nil {
+		return addr.To4(), nil
+	}
+	return addr, nil
+}
@@ -0,0 +1,177 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package utils
+
+import (
+	"errors"
+	"net"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// IpsecSA is an IKE SA reported by "ipsec statusall" of strongSwan.
+type IpsecSA struct {
+	Name        string           `json:"name"`
+	ID          string           `json:"id"`
+	State       string           `json:"state"`
+	Since       string           `json:"since,omitempty"`
+	Local       string           `json:"local"`
+	LocalID     string           `json:"localId"`
+	Remote      string           `json:"remote"`
+	RemoteID    string           `json:"remoteId"`
+	Rekey       string           `json:"rekey,omitempty"`
+	Children    []IpsecChildSA   `json:"children,omitempty"`
+	Peer        string           `json:"peer,omitempty"`
+	Connections []string         `json:"connections,omitempty"`
+	Probe       *IpsecProbeState `json:"probe,omitempty"`
+}
+
+// IpsecChildSA is a CHILD SA, carrying traffic of an IKE SA.
+type IpsecChildSA struct {
+	ID       string `json:"id"`
+	State    string `json:"state"`
+	BytesIn  int64  `json:"bytesIn"`
+	BytesOut int64  `json:"bytesOut"`
+	Rekey    string `json:"rekey,omitempty"`
+	LocalTS  string `json:"localTS,omitempty"`
+	RemoteTS string `json:"remoteTS,omitempty"`
+}
+
+// IpsecProbeState is the result of a ping across a tunnel.
+type IpsecProbeState struct {
+	Target string `json:"target"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+var (
+	// conn[1]: ESTABLISHED 5 minutes ago, 10.10.70.49[CN=a]...10.10.70.39[CN=b]
+	ikeStateRegexp = regexp.MustCompile(`^\s*(\S+)\[(\d+)\]: ([A-Z_]+)( [^,]*)?, (\S+?)\[(.*?)\]\.\.\.(\S+?)\[(.*?)\]\s*$`)
+	// conn[1]: IKEv2 SPIs: 1_i* 2_r, rekeying in 2 hours
+	ikeRekeyRegexp = regexp.MustCompile(`^\s*(\S+)\[(\d+)\]: IKEv\d SPIs: .*?, (?:rekeying|reauthentication) in (.+)$`)
+	// conn{1}:  INSTALLED, TUNNEL, reqid 1, ESP SPIs: c1_i c2_o
+	childStateRegexp = regexp.MustCompile(`^\s*(\S+)\{(\d+)\}:\s+([A-Z_]+), `)
+	// conn{1}:  AES_CBC_128/HMAC_SHA2_256_128, 1234 bytes_i (10 pkts, 3s ago), 5678 bytes_o, rekeying in 45 minutes
+	childBytesRegexp = regexp.MustCompile(`^\s*(\S+)\{(\d+)\}:\s+.*?(\d+) bytes_i.*?, (\d+) bytes_o(?: \([^)]*\))?(?:, rekeying in (.+))?$`)
+	// conn{1}:   192.168.0.0/24 === 192.169.0.0/24
+	childTSRegexp = regexp.MustCompile(`^\s*(\S+)\{(\d+)\}:\s+(.+) === (.+)$`)
+)
+
+// ParseIpsecStatus parses SAs from "ipsec statusall" output. IKE SAs are
+// identified by "name[N]" and CHILD SAs by "name{N}", as several SAs share a
+// connection name on a hub or while reauthenticating. strongSwan lists CHILD SAs
+// below their IKE SA, so they are attached to the last IKE SA of their name.
+func ParseIpsecStatus(output string) []IpsecSA {
+	var sas []IpsecSA
+	ikeByID := make(map[string]int)
+	lastIKE := make(map[string]int)
+	children := make(map[string]*IpsecChildSA)
+	var childOrder []string
+	childIKE := make(map[string]int)
+
+	child := func(name string, id string) *IpsecChildSA {
+		key := name + "{" + id + "}"
+		if c, ok := children[key]; ok {
+			return c
+		}
+		c := &IpsecChildSA{ID: id}
+		children[key] = c
+		childOrder = append(childOrder, key)
+		if i, ok := lastIKE[name]; ok {
+			childIKE[key] = i
+		} else {
+			childIKE[key] = -1
+		}
+		return c
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		if m := ikeStateRegexp.FindStringSubmatch(line); m != nil {
+			ikeID := m[1] + "[" + m[2] + "]"
+			if _, ok := ikeByID[ikeID]; ok {
+				continue
+			}
+			sas = append(sas, IpsecSA{
+				Name:     m[1],
+				ID:       m[2],
+				State:    m[3],
+				Since:    strings.TrimSpace(m[4]),
+				Local:    m[5],
+				LocalID:  m[6],
+				Remote:   m[7],
+				RemoteID: m[8],
+			})
+			ikeByID[ikeID] = len(sas) - 1
+			lastIKE[m[1]] = len(sas) - 1
+			continue
+		}
+		if m := ikeRekeyRegexp.FindStringSubmatch(line); m != nil {
+			if i, ok := ikeByID[m[1]+"["+m[2]+"]"]; ok {
+				sas[i].Rekey = m[3]
+			}
+			continue
+		}
+		if m := childStateRegexp.FindStringSubmatch(line); m != nil {
+			child(m[1], m[2]).State = m[3]
+			continue
+		}
+		if m := childBytesRegexp.FindStringSubmatch(line); m != nil {
+			c := child(m[1], m[2])
+			c.BytesIn, _ = strconv.ParseInt(m[3], 10, 64)
+			c.BytesOut, _ = strconv.ParseInt(m[4], 10, 64)
+			c.Rekey = m[5]
+			continue
+		}
+		if m := childTSRegexp.FindStringSubmatch(line); m != nil {
+			c := child(m[1], m[2])
+			c.LocalTS = strings.TrimSpace(m[3])
+			c.RemoteTS = strings.TrimSpace(m[4])
+		}
+	}
+
+	for _, key := range childOrder {
+		if i := childIKE[key]; i >= 0 {
+			sas[i].Children = append(sas[i].Children, *children[key])
+		}
+	}
+	return sas
+}
+
+// CNFExec runs a command in the CNF pod and returns its output.
+func CNFExec(args ...string) (string, error) {
+	podName, err := FindPodFullname(RouteGatewayPod)
+	if err != nil {
+		return "", err
+	}
+	if podName == "" {
+		return "", errors.New("CNF pod is not found in namespace " + NameSpaceName)
+	}
+	execArgs := append([]string{"exec", "-n", NameSpaceName, podName, "--"}, args...)
+	output, err := exec.Command("kubectl", execArgs...).CombinedOutput()
+	if err != nil {
+		return string(output), errors.New("Failed to run " + strings.Join(args, " ") + " in CNF: " + err.Error())
+	}
+	return string(output), nil
+}
+
+// TSHostIP returns the address of a traffic selector which covers a single
+// host, e.g. "192.169.0.5/32", or "" otherwise.
+func TSHostIP(ts string) string {
+	fields := strings.Fields(ts)
+	if len(fields) == 0 {
+		return ""
+	}
+	ip, ipNet, err := net.ParseCIDR(fields[0])
+	if err != nil {
+		return ""
+	}
+	ones, bits := ipNet.Mask.Size()
+	if ones != bits {
+		return ""
+	}
+	return ip.String()
+}
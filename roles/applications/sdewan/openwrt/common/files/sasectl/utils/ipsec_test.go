@@ -0,0 +1,107 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+// "ipsec statusall" of a hub with two edges on one connection name, and of an
+// edge while its IKE SA is being reauthenticated.
+const (
+	hubStatusall = `Status of IKE charon daemon (strongSwan 5.9.2, Linux 5.4.0-110-generic, x86_64):
+  uptime: 2 hours, since May 17 08:12:01 2022
+  worker threads: 11 of 16 idle, 5/0/0/0 working, job queue: 0/0/0/0, scheduled: 6
+  loaded plugins: charon aes des rc2 sha2 sha1 md5 random nonce x509 pubkey pkcs1 pem openssl kernel-netlink socket-default stroke updown
+Listening IP addresses:
+  10.10.70.39
+  172.16.70.39
+Connections:
+pop1edge1:  %any...%any  IKEv2
+pop1edge1:   local:  [CN=hub-pop1-cert] uses public key authentication
+pop1edge1:   remote: [CN=device-edge-1-cert] uses public key authentication
+Connedge1_192169005:   child:  0.0.0.0/0 === dynamic TUNNEL
+Security Associations (2 up, 0 connecting):
+   pop1edge1[3]: ESTABLISHED 12 minutes ago, 10.10.70.39[CN=hub-pop1-cert]...10.10.70.5[CN=device-edge-1-cert]
+   pop1edge1[3]: IKEv2 SPIs: 2ab1c3d4e5f60718_i 8a7b6c5d4e3f2a1b_r*, rekeying in 2 hours
+   pop1edge1[3]: IKE proposal: AES_CBC_128/HMAC_SHA2_256_128/PRF_HMAC_SHA2_256/MODP_2048
+pop1edge1{5}:  INSTALLED, TUNNEL, reqid 1, ESP SPIs: c1a2b3c4_i c5d6e7f8_o
+pop1edge1{5}:  AES_CBC_128/HMAC_SHA2_256_128, 1234 bytes_i (10 pkts, 3s ago), 5678 bytes_o (12 pkts, 3s ago), rekeying in 45 minutes
+pop1edge1{5}:   0.0.0.0/0 === 192.169.0.5/32
+   pop1edge1[4]: ESTABLISHED 2 minutes ago, 10.10.70.39[CN=hub-pop1-cert]...10.10.70.6[CN=device-edge-2-cert]
+   pop1edge1[4]: IKEv2 SPIs: 0102030405060708_i 1112131415161718_r*, rekeying in 3 hours
+pop1edge1{6}:  INSTALLED, TUNNEL, reqid 2, ESP SPIs: d1a2b3c4_i d5d6e7f8_o
+pop1edge1{6}:  AES_CBC_128/HMAC_SHA2_256_128, 42 bytes_i, 0 bytes_o, rekeying in 50 minutes
+pop1edge1{6}:   0.0.0.0/0 === 192.169.0.6/32
+`
+	edgeStatusall = `Security Associations (2 up, 0 connecting):
+   edge1pop1[7]: ESTABLISHED 2 hours ago, 10.10.70.5[CN=device-edge-1-cert]...10.10.70.39[CN=hub-pop1-cert]
+   edge1pop1[7]: IKEv2 SPIs: 2ab1c3d4e5f60718_i* 8a7b6c5d4e3f2a1b_r, reauthentication in 10 seconds
+edge1pop1{9}:  INSTALLED, TUNNEL, reqid 1, ESP SPIs: c5d6e7f8_i c1a2b3c4_o
+edge1pop1{9}:  AES_CBC_128/HMAC_SHA2_256_128, 100 bytes_i, 200 bytes_o, rekeying in 5 minutes
+edge1pop1{9}:   192.169.0.5/32 === 0.0.0.0/0
+   edge1pop1[8]: CONNECTING, 10.10.70.5[%any]...10.10.70.39[%any]
+`
+)
+
+func TestParseIpsecStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []IpsecSA
+	}{
+		{
+			name:   "hub with two devices on one connection",
+			output: hubStatusall,
+			want: []IpsecSA{
+				{
+					Name: "pop1edge1", ID: "3", State: "ESTABLISHED", Since: "12 minutes ago",
+					Local: "10.10.70.39", LocalID: "CN=hub-pop1-cert", Remote: "10.10.70.5", RemoteID: "CN=device-edge-1-cert",
+					Rekey: "2 hours",
+					Children: []IpsecChildSA{{ID: "5", State: "INSTALLED", BytesIn: 1234, BytesOut: 5678,
+						Rekey: "45 minutes", LocalTS: "0.0.0.0/0", RemoteTS: "192.169.0.5/32"}},
+				},
+				{
+					Name: "pop1edge1", ID: "4", State: "ESTABLISHED", Since: "2 minutes ago",
+					Local: "10.10.70.39", LocalID: "CN=hub-pop1-cert", Remote: "10.10.70.6", RemoteID: "CN=device-edge-2-cert",
+					Rekey: "3 hours",
+					Children: []IpsecChildSA{{ID: "6", State: "INSTALLED", BytesIn: 42, BytesOut: 0,
+						Rekey: "50 minutes", LocalTS: "0.0.0.0/0", RemoteTS: "192.169.0.6/32"}},
+				},
+			},
+		},
+		{
+			name:   "edge reauthenticating",
+			output: edgeStatusall,
+			want: []IpsecSA{
+				{
+					Name: "edge1pop1", ID: "7", State: "ESTABLISHED", Since: "2 hours ago",
+					Local: "10.10.70.5", LocalID: "CN=device-edge-1-cert", Remote: "10.10.70.39", RemoteID: "CN=hub-pop1-cert",
+					Rekey: "10 seconds",
+					Children: []IpsecChildSA{{ID: "9", State: "INSTALLED", BytesIn: 100, BytesOut: 200,
+						Rekey: "5 minutes", LocalTS: "192.169.0.5/32", RemoteTS: "0.0.0.0/0"}},
+				},
+				{
+					Name: "edge1pop1", ID: "8", State: "CONNECTING",
+					Local: "10.10.70.5", LocalID: "%any", Remote: "10.10.70.39", RemoteID: "%any",
+				},
+			},
+		},
+		{
+			name:   "no SAs",
+			output: "Security Associations (0 up, 0 connecting):\n  none\n",
+			want:   nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseIpsecStatus(tt.output)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseIpsecStatus() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,375 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// KubeConfigExtensionName is the name of the kubeconfig extension holding KubeConfigMeta.
+const KubeConfigExtensionName = "sasectl"
+
+// KubeConfigMeta describes the cluster which exported a kubeconfig, so that
+// the overlay controller does not need to guess it on registration.
+type KubeConfigMeta struct {
+	Role     string `yaml:"role"`
+	PublicIP string `yaml:"publicIP,omitempty"`
+}
+
+// KubeConfig is the subset of kubeconfig v1 format handled by sasectl.
+type KubeConfig struct {
+	APIVersion     string                 `yaml:"apiVersion"`
+	Kind           string                 `yaml:"kind"`
+	Preferences    map[string]interface{} `yaml:"preferences"`
+	Clusters       []KubeNamedCluster     `yaml:"clusters"`
+	Users          []KubeNamedUser        `yaml:"users"`
+	Contexts       []KubeNamedContext     `yaml:"contexts"`
+	CurrentContext string                 `yaml:"current-context"`
+	Extensions     []KubeNamedExtension   `yaml:"extensions,omitempty"`
+
+	// Directory of the loaded file, relative certificate paths refer to it.
+	dir string
+}
+
+type KubeNamedCluster struct {
+	Name    string      `yaml:"name"`
+	Cluster KubeCluster `yaml:"cluster"`
+}
+
+type KubeCluster struct {
+	Server                   string `yaml:"server"`
+	CertificateAuthority     string `yaml:"certificate-authority,omitempty"`
+	CertificateAuthorityData string `yaml:"certificate-authority-data,omitempty"`
+	InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify,omitempty"`
+	TLSServerName            string `yaml:"tls-server-name,omitempty"`
+	ProxyURL                 string `yaml:"proxy-url,omitempty"`
+}
+
+type KubeNamedUser struct {
+	Name string   `yaml:"name"`
+	User KubeUser `yaml:"user"`
+}
+
+type KubeUser struct {
+	ClientCertificate     string                 `yaml:"client-certificate,omitempty"`
+	ClientCertificateData string                 `yaml:"client-certificate-data,omitempty"`
+	ClientKey             string                 `yaml:"client-key,omitempty"`
+	ClientKeyData         string                 `yaml:"client-key-data,omitempty"`
+	Token                 string                 `yaml:"token,omitempty"`
+	TokenFile             string                 `yaml:"tokenFile,omitempty"`
+	Username              string                 `yaml:"username,omitempty"`
+	Password              string                 `yaml:"password,omitempty"`
+	Exec                  map[string]interface{} `yaml:"exec,omitempty"`
+	AuthProvider          map[string]interface{} `yaml:"auth-provider,omitempty"`
+}
+
+type KubeNamedContext struct {
+	Name    string      `yaml:"name"`
+	Context KubeContext `yaml:"context"`
+}
+
+type KubeContext struct {
+	Cluster   string `yaml:"cluster"`
+	User      string `yaml:"user"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+type KubeNamedExtension struct {
+	Name      string      `yaml:"name"`
+	Extension interface{} `yaml:"extension"`
+}
+
+const saTokenSecretTemplate = `apiVersion: v1
+kind: Secret
+type: kubernetes.io/service-account-token
+metadata:
+  name: %s
+  namespace: %s
+  annotations:
+    kubernetes.io/service-account.name: %s
+`
+
+// KubeConfigPath returns the kubeconfig used by kubectl: $KUBECONFIG when it
+// names a single file, else ~/.kube/config.
+func KubeConfigPath() (string, error) {
+	envPath := os.Getenv("KUBECONFIG")
+	if envPath != "" && !strings.Contains(envPath, string(os.PathListSeparator)) {
+		return envPath, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".kube/config"), nil
+}
+
+func LoadKubeConfig(fp string) (*KubeConfig, error) {
+	data, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return nil, err
+	}
+	var config KubeConfig
+	err = yaml.Unmarshal(data, &config)
+	if err != nil {
+		return nil, errors.New("Failed to parse kube config " + fp + ": " + err.Error())
+	}
+	config.dir = filepath.Dir(fp)
+	return &config, nil
+}
+
+// Minify returns a kubeconfig with only context contextName, or the current
+// context if empty, and with certificates and keys embedded.
+func (c *KubeConfig) Minify(contextName string) (*KubeConfig, error) {
+	if contextName == "" {
+		contextName = c.CurrentContext
+	}
+	if contextName == "" {
+		return nil, errors.New("No current context in kube config")
+	}
+
+	var ctx *KubeNamedContext
+	for i := range c.Contexts {
+		if c.Contexts[i].Name == contextName {
+			ctx = &c.Contexts[i]
+		}
+	}
+	if ctx == nil {
+		return nil, errors.New("Context " + contextName + " not found in kube config")
+	}
+
+	var cluster *KubeNamedCluster
+	for i := range c.Clusters {
+		if c.Clusters[i].Name == ctx.Context.Cluster {
+			cluster = &c.Clusters[i]
+		}
+	}
+	if cluster == nil {
+		return nil, errors.New("Cluster " + ctx.Context.Cluster + " of context " + contextName + " not found in kube config")
+	}
+
+	var user *KubeNamedUser
+	for i := range c.Users {
+		if c.Users[i].Name == ctx.Context.User {
+			user = &c.Users[i]
+		}
+	}
+	if user == nil {
+		return nil, errors.New("User " + ctx.Context.User + " of context " + contextName + " not found in kube config")
+	}
+
+	minCluster := *cluster
+	err := embedFileData(c.dir, &minCluster.Cluster.CertificateAuthority, &minCluster.Cluster.CertificateAuthorityData)
+	if err != nil {
+		return nil, err
+	}
+
+	minUser := *user
+	if minUser.User.Exec != nil || minUser.User.AuthProvider != nil {
+		return nil, errors.New("User " + minUser.Name + " authenticates with an external plugin which can not be exported, use a service account")
+	}
+	err = embedFileData(c.dir, &minUser.User.ClientCertificate, &minUser.User.ClientCertificateData)
+	if err != nil {
+		return nil, err
+	}
+	err = embedFileData(c.dir, &minUser.User.ClientKey, &minUser.User.ClientKeyData)
+	if err != nil {
+		return nil, err
+	}
+	if minUser.User.TokenFile != "" {
+		token, err := ioutil.ReadFile(resolveKubePath(c.dir, minUser.User.TokenFile))
+		if err != nil {
+			return nil, errors.New("Failed to read token file of user " + minUser.Name + ": " + err.Error())
+		}
+		minUser.User.Token = strings.TrimSpace(string(token))
+		minUser.User.TokenFile = ""
+	}
+
+	return &KubeConfig{
+		APIVersion:     "v1",
+		Kind:           "Config",
+		Preferences:    map[string]interface{}{},
+		Clusters:       []KubeNamedCluster{minCluster},
+		Users:          []KubeNamedUser{minUser},
+		Contexts:       []KubeNamedContext{*ctx},
+		CurrentContext: contextName,
+	}, nil
+}
+
+// Server returns the API server url of the current context.
+func (c *KubeConfig) Server() string {
+	cluster := c.currentCluster()
+	if cluster == nil {
+		return ""
+	}
+	return cluster.Server
+}
+
+// SetServer overrides the API server url of the current context, e.g. with an
+// address reachable from the overlay controller.
+func (c *KubeConfig) SetServer(server string) error {
+	u, err := url.Parse(server)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return errors.New("Invalid API server url " + server + ", use https://<address>:<port>")
+	}
+	cluster := c.currentCluster()
+	if cluster == nil {
+		return errors.New("No cluster for current context in kube config")
+	}
+	cluster.Server = server
+	return nil
+}
+
+// UseToken replaces the credentials of the current context with a bearer token.
+func (c *KubeConfig) UseToken(userName string, token string) error {
+	for i := range c.Contexts {
+		if c.Contexts[i].Name == c.CurrentContext {
+			c.Contexts[i].Context.User = userName
+			c.Users = []KubeNamedUser{{Name: userName, User: KubeUser{Token: token}}}
+			return nil
+		}
+	}
+	return errors.New("Context " + c.CurrentContext + " not found in kube config")
+}
+
+// SetMeta embeds meta into the top level extensions.
+func (c *KubeConfig) SetMeta(meta *KubeConfigMeta) {
+	var exts []KubeNamedExtension
+	for _, ext := range c.Extensions {
+		if ext.Name != KubeConfigExtensionName {
+			exts = append(exts, ext)
+		}
+	}
+	c.Extensions = append(exts, KubeNamedExtension{Name: KubeConfigExtensionName, Extension: meta})
+}
+
+// Marshal encodes the kubeconfig with the indentation kubectl uses.
+func (c *KubeConfig) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	err := encoder.Encode(c)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Close()
+	return buf.Bytes(), err
+}
+
+func (c *KubeConfig) currentCluster() *KubeCluster {
+	for _, ctx := range c.Contexts {
+		if ctx.Name != c.CurrentContext {
+			continue
+		}
+		for i := range c.Clusters {
+			if c.Clusters[i].Name == ctx.Context.Cluster {
+				return &c.Clusters[i].Cluster
+			}
+		}
+	}
+	return nil
+}
+
+// KubeServerHost returns the host name of an API server url.
+func KubeServerHost(server string) (string, error) {
+	u, err := url.Parse(server)
+	if err != nil || u.Hostname() == "" {
+		return "", errors.New("Invalid API server url " + server)
+	}
+	return u.Hostname(), nil
+}
+
+// GetKubeConfigMeta returns the meta embedded in kubeConfig, or nil if there is none.
+func GetKubeConfigMeta(kubeConfig []byte) (*KubeConfigMeta, error) {
+	var config struct {
+		Extensions []struct {
+			Name      string         `yaml:"name"`
+			Extension KubeConfigMeta `yaml:"extension"`
+		} `yaml:"extensions"`
+	}
+	err := yaml.Unmarshal(kubeConfig, &config)
+	if err != nil {
+		return nil, errors.New("Failed to parse kube config: " + err.Error())
+	}
+	for _, ext := range config.Extensions {
+		if ext.Name == KubeConfigExtensionName {
+			meta := ext.Extension
+			return &meta, nil
+		}
+	}
+	return nil, nil
+}
+
+// LoadKubeConfigMeta reads the meta embedded in kubeconfig file fp.
+func LoadKubeConfigMeta(fp string) (*KubeConfigMeta, error) {
+	data, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return nil, err
+	}
+	return GetKubeConfigMeta(data)
+}
+
+// GetServiceAccountToken returns a long-lived token of service account name,
+// issued through a service-account-token secret which is created if missing.
+func GetServiceAccountToken(namespace string, name string) (string, error) {
+	secretName := name + "-token"
+	manifest := fmt.Sprintf(saTokenSecretTemplate, secretName, namespace, name)
+	applyCmd := exec.Command("kubectl", "apply", "-f", "-")
+	applyCmd.Stdin = strings.NewReader(manifest)
+	output, err := applyCmd.CombinedOutput()
+	if err != nil {
+		return "", errors.New("Failed to create token secret for service account " + name + ": " + string(output))
+	}
+	AuditChange("kubectl apply secret " + namespace + "/" + secretName)
+
+	deadline := time.Now().Add(SATokenTimeout)
+	for {
+		output, err = exec.Command("kubectl", "get", "secret", secretName, "-n", namespace,
+			"-o", "jsonpath={.data.token}").Output()
+		if err == nil && len(output) > 0 {
+			token, err := base64.StdEncoding.DecodeString(string(output))
+			if err != nil {
+				return "", errors.New("Failed to decode token of service account " + name + ": " + err.Error())
+			}
+			return string(token), nil
+		}
+		if time.Now().After(deadline) {
+			return "", errors.New("Timeout waiting for token of service account " + name)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// embedFileData moves the content of file *path, if any, base64 encoded into *data.
+func embedFileData(dir string, path *string, data *string) error {
+	if *path == "" {
+		return nil
+	}
+	content, err := ioutil.ReadFile(resolveKubePath(dir, *path))
+	if err != nil {
+		return errors.New("Failed to embed " + *path + " into kube config: " + err.Error())
+	}
+	*data = base64.StdEncoding.EncodeToString(content)
+	*path = ""
+	return nil
+}
+
+func resolveKubePath(dir string, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,121 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package utils
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetricsContentType is the Prometheus text exposition format.
+const MetricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// MetricFamily is a metric of Prometheus text format with its samples.
+type MetricFamily struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []MetricSample
+}
+
+// MetricSample is a value of a metric family with labels in their order.
+type MetricSample struct {
+	Labels []MetricLabel
+	Value  float64
+}
+
+type MetricLabel struct {
+	Name  string
+	Value string
+}
+
+// Add appends a sample with labels given as name, value pairs.
+func (f *MetricFamily) Add(value float64, labels ...string) {
+	sample := MetricSample{Value: value}
+	for i := 0; i+1 < len(labels); i += 2 {
+		sample.Labels = append(sample.Labels, MetricLabel{Name: labels[i], Value: labels[i+1]})
+	}
+	f.Samples = append(f.Samples, sample)
+}
+
+// WriteMetrics writes families in Prometheus text format. Families without
+// samples are skipped.
+func WriteMetrics(w io.Writer, families []*MetricFamily) error {
+	var b strings.Builder
+	for _, f := range families {
+		if len(f.Samples) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "# HELP %s %s\n", f.Name, f.Help)
+		fmt.Fprintf(&b, "# TYPE %s %s\n", f.Name, f.Type)
+		for _, s := range f.Samples {
+			b.WriteString(f.Name)
+			if len(s.Labels) > 0 {
+				var labels []string
+				for _, l := range s.Labels {
+					labels = append(labels, l.Name+"=\""+escapeLabelValue(l.Value)+"\"")
+				}
+				b.WriteString("{" + strings.Join(labels, ",") + "}")
+			}
+			b.WriteString(" " + strconv.FormatFloat(s.Value, 'g', -1, 64) + "\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func escapeLabelValue(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `"`, `\"`)
+	return strings.ReplaceAll(v, "\n", `\n`)
+}
+
+// ParseIpsecDuration parses durations of strongSwan status, e.g. "45 minutes".
+func ParseIpsecDuration(s string) (time.Duration, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return 0, errors.New("Invalid duration " + s)
+	}
+	n, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, errors.New("Invalid duration " + s)
+	}
+	units := map[string]time.Duration{
+		"second": time.Second,
+		"minute": time.Minute,
+		"hour":   time.Hour,
+		"day":    24 * time.Hour,
+	}
+	unit, ok := units[strings.TrimSuffix(fields[1], "s")]
+	if !ok {
+		return 0, errors.New("Invalid duration " + s)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// CertificateNotAfter returns the expiry of a base64 encoded PEM certificate,
+// as certificate objects of SCC carry them.
+func CertificateNotAfter(encoded string) (time.Time, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return time.Time{}, errors.New("Failed to decode certificate: " + err.Error())
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return time.Time{}, errors.New("Certificate is not PEM encoded")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cert.NotAfter, nil
+}
@@ -0,0 +1,92 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+)
+
+const (
+	// Service account used by overlay controller to deploy sdewan CRs on edge.
+	EdgeServiceAccountName = "sasectl-overlay"
+	SdewanAPIGroup         = "batch.sdewan.akraino.org"
+)
+
+// Namespaces where overlay controller manages sdewan CRs of an edge.
+var EdgeServiceAccountNamespaces = []string{"default", NameSpaceName}
+
+const edgeServiceAccountTemplate = `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: {{NAME}}
+  namespace: {{SA_NAMESPACE}}
+`
+
+const edgeRoleTemplate = `---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: {{NAME}}
+  namespace: {{NAMESPACE}}
+rules:
+- apiGroups: ["{{API_GROUP}}"]
+  resources: ["*"]
+  verbs: ["get", "list", "watch", "create", "update", "patch", "delete"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: {{NAME}}
+  namespace: {{NAMESPACE}}
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: Role
+  name: {{NAME}}
+subjects:
+- kind: ServiceAccount
+  name: {{NAME}}
+  namespace: {{SA_NAMESPACE}}
+`
+
+// ApplyEdgeServiceAccount creates the service account of overlay controller,
+// allowed to manage sdewan CRs only in EdgeServiceAccountNamespaces.
+func ApplyEdgeServiceAccount() error {
+	return kubectlServiceAccount("apply", edgeServiceAccountManifest())
+}
+
+// DeleteEdgeServiceAccount removes the service account, its token and its roles.
+func DeleteEdgeServiceAccount() error {
+	manifest := edgeServiceAccountManifest() + "---\n" +
+		fmt.Sprintf(saTokenSecretTemplate, EdgeServiceAccountName+"-token", NameSpaceName, EdgeServiceAccountName)
+	return kubectlServiceAccount("delete", manifest, "--ignore-not-found")
+}
+
+func edgeServiceAccountManifest() string {
+	manifest := edgeServiceAccountTemplate
+	for _, ns := range EdgeServiceAccountNamespaces {
+		manifest += strings.ReplaceAll(edgeRoleTemplate, "{{NAMESPACE}}", ns)
+	}
+	replacer := strings.NewReplacer(
+		"{{NAME}}", EdgeServiceAccountName,
+		"{{SA_NAMESPACE}}", NameSpaceName,
+		"{{API_GROUP}}", SdewanAPIGroup,
+	)
+	return replacer.Replace(manifest)
+}
+
+func kubectlServiceAccount(action string, manifest string, args ...string) error {
+	kubectlCmd := exec.Command("kubectl", append([]string{action, "-f", "-"}, args...)...)
+	kubectlCmd.Stdin = strings.NewReader(manifest)
+	output, err := kubectlCmd.CombinedOutput()
+	log.Println(string(output))
+	if err != nil {
+		return errors.New("Failed to " + action + " service account " + EdgeServiceAccountName + ": " + err.Error())
+	}
+	return nil
+}
@@ -4,6 +4,8 @@
 **/
 package utils
 
+import "time"
+
 const (
 	NameSpaceName               = "sdewan-system"
 	RootIssuerName              = "sdewan-controller"
@@ -34,6 +36,36 @@ const (
 	Resource                    = "resource"
 	Resource_Status_NotDeployed = "NotDeployed"
 	Resource_Status_Deployed    = "Deployed"
+	SCCServiceName              = "scc"
+)
+
+const (
+	// Routing table of host policy routing used unless configured, and the
+	// range to allocate from.
+	OverlayRouteTable = 40
+	RouteTableMin     = 1
+	RouteTableMax     = 252
+	// Routing table in the network namespace of CNF, which sasectl does not
+	// share with anything else.
+	CNFRouteTable = 40
+)
+
+// Device types which can be registered on overlay controller.
+var DeviceTypes = []string{"edge", "pop", "popoverlay"}
+
+const (
+	SCCDialTimeout        = 3 * time.Second
+	SCCPortForwardTimeout = 15 * time.Second
+	SCCReadyTimeout       = 5 * time.Minute
+	SCCReadyInterval      = 5 * time.Second
+	RestDefaultTimeout    = 30 * time.Second
+	RestDefaultRetries    = 3
+	CompletionRestTimeout = 3 * time.Second
+	RestInitialBackoff    = 1 * time.Second
+	RestMaxBackoff        = 30 * time.Second
+	SATokenTimeout        = 30 * time.Second
+	RolloutTimeout        = 10 * time.Minute
+	ExporterInterval      = 30 * time.Second
 )
 
 const CNFValueCopyright = `#/* Copyright (c) 2021 Intel Corporation, Inc
@@ -75,6 +107,9 @@ const CNFBaseShell = ` #!/bin/bash
 # Always exit on errors.
 set -ex
 sysctl -w net.ipv4.ip_forward=1
+{{- if .Values.providerCIDR6 }}
+sysctl -w net.ipv6.conf.all.forwarding=1
+{{- end }}
 echo "" > /etc/config/network
 cat > /etc/config/mwan3 <<EOF
 config globals 'globals'
@@ -82,24 +117,30 @@ config globals 'globals'
 	option local_source 'lan'
 EOF
 
-providerip=$(echo {{ .Values.providerCIDR }} | cut -d/ -f1)
-sep="."
-suf="0"
-
 eval "networks=$(grep nfn-network /tmp/podinfo/annotations | awk  -F '=' '{print $2}')"
 for net in $(echo -e $networks | jq -c ".interface[]")
 do
   interface=$(echo $net | jq -r .interface)
-  ipaddr=$(ifconfig $interface | awk '/inet/{print $2}' | cut -f2 -d ":" | awk 'NR==1 {print $1}')
+  ipaddr=$(ifconfig $interface | awk '/inet addr/{print $2}' | cut -f2 -d ":" | awk 'NR==1 {print $1}')
   vif="$interface"
-  netmask=$(ifconfig $interface | awk '/inet/{print $4}'| cut -f2 -d ":" | head -1)
+  netmask=$(ifconfig $interface | awk '/inet addr/{print $4}'| cut -f2 -d ":" | head -1)
+  ip6addr=$(ifconfig $interface | awk '/inet6 addr/ && /Scope:Global/{print $3}' | head -1)
   cat >> /etc/config/network <<EOF
 config interface '$vif'
 	option ifname '$interface'
 	option proto 'static'
+EOF
+  if [ -n "$ipaddr" ]; then
+    cat >> /etc/config/network <<EOF
 	option ipaddr '$ipaddr'
 	option netmask '$netmask'
 EOF
+  fi
+  if [ -n "$ip6addr" ]; then
+    cat >> /etc/config/network <<EOF
+	option ip6addr '$ip6addr'
+EOF
+  fi
 done
 
 if [ -f "/tmp/sdewan/account/password" ]; then
@@ -129,21 +170,54 @@ sleep 1
 /etc/init.d/firewall restart
 defaultip=$(grep "\podIP\b" /tmp/podinfo/annotations | cut -d/ -f2 | cut -d'"' -f2)`
 
+// CNFTemplateShell DNATs kube API on public IPs of CNF, to apiServiceIP6 for an
+// IPv6 public IP. defaultCIDR may hold an IPv4 and an IPv6 pod CIDR separated by
+// comma on a dual-stack cluster.
 const CNFTemplateShell = `{{- if .Values.publicIpAddress }}
+{{- if contains ":" .Values.publicIpAddress }}
+{{- if .Values.apiServiceIP6 }}
+    ip6tables -t nat -I PREROUTING 1 -m tcp -p tcp -d {{ .Values.publicIpAddress }} --dport 6443 -j DNAT --to-dest [{{ .Values.apiServiceIP6 }}]:443
+{{- end }}
+{{- else }}
     iptables -t nat -I PREROUTING 1 -m tcp -p tcp -d {{ .Values.publicIpAddress }} --dport 6443 -j DNAT --to-dest 10.96.0.1:443
 {{- end }}
+{{- end }}
+{{- if .Values.popPublicIpAddress }}
+    iptables -t nat -I PREROUTING 1 -m tcp -p tcp -d {{ .Values.popPublicIpAddress }} --dport 6443 -j DNAT --to-dest 10.96.0.1:443
+{{- end }}
 {{- if .Values.defaultCIDR }}
-    ip rule add from {{ .Values.defaultCIDR }} lookup 40
-    ip rule add from $defaultip lookup main
+{{- range splitList "," .Values.defaultCIDR }}
+{{- if contains ":" . }}
+    ip -6 rule add from {{ trim . }} lookup {{ $.Values.routeTable | default 40 }}
+{{- else }}
+    ip rule add from {{ trim . }} lookup {{ $.Values.routeTable | default 40 }}
+{{- end }}
+{{- end }}
+    case "$defaultip" in
+    *:*) ip -6 rule add from $defaultip lookup main ;;
+    *) ip rule add from $defaultip lookup main ;;
+    esac
 {{- end }}
     echo "Entering sleep... (success)"
     # Sleep forever.
     while true; do sleep 100; done`
 
-const CNFRouterShell = `for net in $(echo -e $networks | jq -c ".interface[]")
-do
-	interface=$(echo $net | jq -r .interface)
-	ipaddr=$(ifconfig $interface | awk '/inet/{print $2}' | cut -f2 -d ":" | awk 'NR==1 {print $1}')
-	echo $ipaddr | ( IFS="." read -r var1 var2 var3 var4; CIDR="$var1$sep$var2$sep$var3$sep$suf"; \
-		if [ "${CIDR}" = "${providerip}" ] ; then iptables -t nat -A POSTROUTING -o $interface -d {{ .Values.providerCIDR }} -j SNAT --to-source $ipaddr; fi)
-done`
+// CNFRouterShell SNATs traffic to the provider networks, IPv4 providerCIDR and
+// IPv6 providerCIDR6, to the address of CNF on the interface attached to them.
+const CNFRouterShell = `snat_provider() {
+	route=$(ip route get $(echo $1 | cut -d/ -f1) | head -1)
+	case "$route" in
+	*" via "*) return 0 ;;
+	esac
+	dev=$(echo "$route" | sed -n 's/.* dev \([^ ]*\).*/\1/p')
+	src=$(echo "$route" | sed -n 's/.* src \([^ ]*\).*/\1/p')
+	if [ -n "$dev" ] && [ -n "$src" ]; then
+		$2 -t nat -A POSTROUTING -o $dev -d $1 -j SNAT --to-source $src
+	fi
+}
+{{- if .Values.providerCIDR }}
+snat_provider {{ .Values.providerCIDR }} iptables
+{{- end }}
+{{- if .Values.providerCIDR6 }}
+snat_provider {{ .Values.providerCIDR6 }} ip6tables
+{{- end }}`
@@ -0,0 +1,241 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	restClient  *http.Client
+	restContext *SCCContext
+	restTimeout = RestDefaultTimeout
+	restRetries = RestDefaultRetries
+)
+
+// SetRestPolicy sets the per-request timeout and how many times CallRest
+// retries a failed request.
+func SetRestPolicy(timeout time.Duration, retries int) {
+	if timeout > 0 {
+		restTimeout = timeout
+	}
+	if retries >= 0 {
+		restRetries = retries
+	}
+}
+
+// SetRestContext sets the context whose TLS and authentication CallRest uses.
+// A nil context keeps the plain HTTP client without authentication. Files of
+// the context are read on the first request, so that commands not calling SCC
+// work for users who can not read them.
+func SetRestContext(ctx *SCCContext) {
+	restContext = ctx
+	restClient = nil
+}
+
+// restHTTPClient returns the client of the rest context, set up on first use.
+func restHTTPClient() (*http.Client, error) {
+	if restClient != nil {
+		return restClient, nil
+	}
+	client, err := newRestClient(restContext)
+	if err != nil {
+		return nil, err
+	}
+	restClient = client
+	return restClient, nil
+}
+
+func newRestClient(ctx *SCCContext) (*http.Client, error) {
+	if ctx == nil || !ctx.useTLS() {
+		return &http.Client{}, nil
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         ctx.TLSServerName,
+		InsecureSkipVerify: ctx.InsecureSkipVerify,
+	}
+
+	if ctx.CAFile != "" {
+		caPem, err := ioutil.ReadFile(ctx.CAFile)
+		if err != nil {
+			return nil, errors.New("Failed to read CA bundle " + ctx.CAFile + ": " + err.Error())
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPem) {
+			return nil, errors.New("No valid certificate found in CA bundle " + ctx.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if ctx.CertFile != "" || ctx.KeyFile != "" {
+		if ctx.CertFile == "" || ctx.KeyFile == "" {
+			return nil, errors.New("Both cert-file and key-file are required for client certificate authentication")
+		}
+		cert, err := tls.LoadX509KeyPair(ctx.CertFile, ctx.KeyFile)
+		if err != nil {
+			return nil, errors.New("Failed to load client certificate: " + err.Error())
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		},
+	}, nil
+}
+
+// RestScheme returns the url scheme matching the current rest context.
+func RestScheme() string {
+	if restContext != nil && restContext.useTLS() {
+		return "https"
+	}
+	return "http"
+}
+
+// TLSMode describes how the connection to SCC is secured: "none", "server"
+// or "mutual", with ",insecure" appended if server verification is skipped.
+func (c *SCCContext) TLSMode() string {
+	if !c.useTLS() {
+		return "none"
+	}
+	mode := "server"
+	if c.CertFile != "" {
+		mode = "mutual"
+	}
+	if c.InsecureSkipVerify {
+		mode += ",insecure"
+	}
+	return mode
+}
+
+func (c *SCCContext) useTLS() bool {
+	return c.CAFile != "" || c.CertFile != "" || c.InsecureSkipVerify ||
+		strings.HasPrefix(c.SCCEndpoint, "https://")
+}
+
+func (c *SCCContext) bearerToken() (string, error) {
+	if c.TokenFile != "" {
+		token, err := ioutil.ReadFile(c.TokenFile)
+		if err != nil {
+			return "", errors.New("Failed to read token file " + c.TokenFile + ": " + err.Error())
+		}
+		return strings.TrimSpace(string(token)), nil
+	}
+	return "", nil
+}
+
+// CallRest sends request to url and returns the response body. GET requests
+// are retried with exponential backoff on connection errors and 5xx responses.
+// Other requests may change SCC state, so they are only retried when SCC could
+// not be connected to, i.e. the request was never sent.
+func CallRest(method string, url string, request string) (string, error) {
+	log.Printf("%s    %s    %s\n", method, url, request)
+	backoff := RestInitialBackoff
+	for attempt := 0; ; attempt++ {
+		body, retry, err := callRestOnce(method, url, request)
+		if err == nil || !retry || attempt >= restRetries {
+			// A failed write may still have reached SCC, so it is recorded too.
+			auditRest(method, url, request, err)
+			return body, err
+		}
+		log.Printf("Request failed (%s), retry %d/%d in %s.", err.Error(), attempt+1, restRetries, backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > RestMaxBackoff {
+			backoff = RestMaxBackoff
+		}
+	}
+}
+
+// callRestOnce sends a single request, and reports whether the failure is safe
+// and worth a retry.
+func callRestOnce(method string, url string, request string) (string, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), restTimeout)
+	defer cancel()
+
+	req_body := bytes.NewBuffer([]byte(request))
+	req, err := http.NewRequestWithContext(ctx, method, url, req_body)
+	if err != nil {
+		return "", false, errors.New("Failed to create request to server: " + err.Error())
+	}
+
+	req.Header.Set("Cache-Control", "no-cache")
+	if restContext != nil {
+		token, err := restContext.bearerToken()
+		if err != nil {
+			return "", false, err
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+
+	client, err := restHTTPClient()
+	if err != nil {
+		return "", false, err
+	}
+	idempotent := method == http.MethodGet
+	resp, err := client.Do(req)
+	if err != nil {
+		tlsErr := checkTLSError(err)
+		return "", tlsErr == err && (idempotent || isDialError(err)), tlsErr
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", idempotent, errors.New("Failed to read returned body content: " + err.Error())
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return "", false, errors.New("SCC rejected the request (" + resp.Status + "), check token or client certificate of current context")
+	}
+
+	if resp.StatusCode >= 500 {
+		return "", idempotent, errors.New(resp.Status + ": " + string(body))
+	}
+
+	if resp.StatusCode >= 400 {
+		return "", false, errors.New(string(body))
+	}
+
+	return string(body), false, nil
+}
+
+// isDialError reports whether err happened while connecting, before any of
+// the request was sent.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// checkTLSError turns certificate validation failures into an actionable error.
+func checkTLSError(err error) error {
+	var unknownAuthErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	switch {
+	case errors.As(err, &unknownAuthErr):
+		return errors.New("SCC server certificate is signed by an unknown authority, set ca-file of current context: " + err.Error())
+	case errors.As(err, &hostnameErr):
+		return errors.New("SCC server certificate does not match the endpoint, set tls-server-name of current context: " + err.Error())
+	case errors.As(err, &invalidErr):
+		return errors.New("SCC server certificate is invalid: " + err.Error())
+	}
+	return err
+}
@@ -0,0 +1,237 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// K8sObjectRef identifies an object defined in a manifest.
+type K8sObjectRef struct {
+	Kind      string
+	Name      string
+	Namespace string
+	// Selector of the pods owned by workload objects, e.g. "app=scc".
+	Selector string
+}
+
+type k8sManifestObject struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+	Spec struct {
+		Selector struct {
+			MatchLabels map[string]string `yaml:"matchLabels"`
+		} `yaml:"selector"`
+	} `yaml:"spec"`
+}
+
+func (o K8sObjectRef) String() string {
+	if o.Namespace == "" {
+		return strings.ToLower(o.Kind) + "/" + o.Name
+	}
+	return o.Namespace + "/" + strings.ToLower(o.Kind) + "/" + o.Name
+}
+
+func (o K8sObjectRef) isWorkload() bool {
+	return o.Kind == "Deployment" || o.Kind == "StatefulSet" || o.Kind == "DaemonSet"
+}
+
+func (o K8sObjectRef) kubectlArgs(args ...string) []string {
+	args = append(args, strings.ToLower(o.Kind)+"/"+o.Name)
+	if o.Namespace != "" {
+		args = append(args, "-n", o.Namespace)
+	}
+	return args
+}
+
+// ParseManifestObjects lists the objects of a multi-document yaml manifest.
+// Objects without namespace get defaultNs.
+func ParseManifestObjects(manifest []byte, defaultNs string) ([]K8sObjectRef, error) {
+	var objs []K8sObjectRef
+	decoder := yaml.NewDecoder(bytes.NewReader(manifest))
+	for {
+		var obj k8sManifestObject
+		err := decoder.Decode(&obj)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if obj.Kind == "" || obj.Metadata.Name == "" {
+			continue
+		}
+		ref := K8sObjectRef{
+			Kind:      obj.Kind,
+			Name:      obj.Metadata.Name,
+			Namespace: obj.Metadata.Namespace,
+		}
+		if ref.Namespace == "" {
+			ref.Namespace = defaultNs
+		}
+		var labels []string
+		for k, v := range obj.Spec.Selector.MatchLabels {
+			labels = append(labels, k+"="+v)
+		}
+		sort.Strings(labels)
+		ref.Selector = strings.Join(labels, ",")
+		objs = append(objs, ref)
+	}
+	return objs, nil
+}
+
+// LoadManifestObjects lists the objects defined in manifest file fp.
+func LoadManifestObjects(fp string, defaultNs string) ([]K8sObjectRef, error) {
+	manifest, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return nil, err
+	}
+	return ParseManifestObjects(manifest, defaultNs)
+}
+
+// GetHelmReleaseObjects lists the objects deployed by a helm release.
+func GetHelmReleaseObjects(release string) ([]K8sObjectRef, error) {
+	output, err := exec.Command("helm", "get", "manifest", release).Output()
+	if err != nil {
+		return nil, errors.New("Failed to get manifest of helm release " + release + ": " + err.Error())
+	}
+	return ParseManifestObjects(output, "")
+}
+
+// GetHelmReleaseValue returns the value at dotted path of the computed values
+// of a helm release, e.g. "spec.sdewan.image", or "" if it is not set.
+func GetHelmReleaseValue(release string, path string) (string, error) {
+	output, err := exec.Command("helm", "get", "values", release, "--all", "-o", "yaml").Output()
+	if err != nil {
+		return "", errors.New("Failed to get values of helm release " + release + ": " + err.Error())
+	}
+	var value interface{}
+	err = yaml.Unmarshal(output, &value)
+	if err != nil {
+		return "", errors.New("Failed to parse values of helm release " + release + ": " + err.Error())
+	}
+	for _, key := range strings.Split(path, ".") {
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return "", nil
+		}
+		value = values[key]
+	}
+	if value == nil {
+		return "", nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", errors.New("Value " + path + " of helm release " + release + " is not a string")
+	}
+	return s, nil
+}
+
+// WaitObjectsReady waits until workloads are rolled out and certificates are
+// issued. Other kinds of objects are ready once created.
+func WaitObjectsReady(objs []K8sObjectRef, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for _, obj := range objs {
+		var args []string
+		switch {
+		case obj.isWorkload():
+			args = obj.kubectlArgs("rollout", "status")
+		case obj.Kind == "Certificate":
+			args = obj.kubectlArgs("wait", "--for=condition=Ready")
+		default:
+			continue
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return errors.New("Timeout waiting for " + obj.String() + " to be ready")
+		}
+		args = append(args, "--timeout="+remaining.Round(time.Second).String())
+
+		log.Printf("Waiting for %s to be ready ...", obj.String())
+		waitCmd := exec.Command("kubectl", args...)
+		waitCmd.Stdout = log.Writer()
+		waitCmd.Stderr = log.Writer()
+		err := waitCmd.Run()
+		if err != nil {
+			return errors.New(obj.String() + " is not ready: " + err.Error())
+		}
+	}
+	return nil
+}
+
+// WaitObjectsDeleted waits until objects, and the pods owned by workloads, are gone.
+func WaitObjectsDeleted(objs []K8sObjectRef, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for _, obj := range objs {
+		targets := []K8sObjectRef{obj}
+		if obj.isWorkload() && obj.Selector != "" {
+			pods, err := listPods(obj.Namespace, obj.Selector)
+			if err != nil {
+				return err
+			}
+			for _, pod := range pods {
+				targets = append(targets, K8sObjectRef{Kind: "Pod", Name: pod, Namespace: obj.Namespace})
+			}
+		}
+		for _, target := range targets {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				return errors.New("Timeout waiting for " + target.String() + " to be deleted")
+			}
+			args := target.kubectlArgs("wait", "--for=delete")
+			args = append(args, "--timeout="+remaining.Round(time.Second).String())
+			output, err := exec.Command("kubectl", args...).CombinedOutput()
+			if err != nil && !strings.Contains(string(output), "NotFound") &&
+				!strings.Contains(string(output), "not found") {
+				log.Print(string(output))
+				return errors.New(target.String() + " is not deleted: " + err.Error())
+			}
+		}
+		log.Printf("%s is deleted.", obj.String())
+	}
+	return nil
+}
+
+// RestartWorkloads restarts the pods of workload objects, e.g. to pick up a
+// changed config map. Other kinds of objects are skipped.
+func RestartWorkloads(objs []K8sObjectRef) error {
+	for _, obj := range objs {
+		if !obj.isWorkload() {
+			continue
+		}
+		output, err := exec.Command("kubectl", obj.kubectlArgs("rollout", "restart")...).CombinedOutput()
+		if err != nil {
+			log.Print(string(output))
+			return errors.New("Failed to restart " + obj.String() + ": " + err.Error())
+		}
+		log.Printf("%s is restarted.", obj.String())
+	}
+	return nil
+}
+
+func listPods(namespace string, selector string) ([]string, error) {
+	args := []string{"get", "pod", "-l", selector, "--no-headers", "-o", "custom-columns=Name:.metadata.name"}
+	if namespace != "" {
+		args = append(args, "-n", namespace)
+	}
+	output, err := exec.Command("kubectl", args...).Output()
+	if err != nil {
+		return nil, errors.New("Failed to list pods with selector " + selector + ": " + err.Error())
+	}
+	return strings.Fields(string(output)), nil
+}
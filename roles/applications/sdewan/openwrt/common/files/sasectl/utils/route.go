@@ -0,0 +1,340 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package utils
+
+import (
+	"errors"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+)
+
+// Policy rules and routes installed by sasectl are tagged, so that only our own
+// entries are touched: rules by a reserved priority range and routes by a
+// dedicated route protocol.
+const (
+	RulePriorityMin      = 10000
+	RulePriorityMax      = 10999
+	RouteProtocolSasectl = 242
+)
+
+// AddPolicyRule adds rule "to <dst> lookup <table>" unless sasectl already has it.
+func AddPolicyRule(dst string, table int) error {
+	_, dstNet, err := net.ParseCIDR(dst)
+	if err != nil {
+		return errors.New("Invalid rule destination " + dst + ": " + err.Error())
+	}
+
+	rules, err := ListPolicyRules(table)
+	if err != nil {
+		return err
+	}
+	used := make(map[int]bool)
+	for _, r := range rules {
+		if r.Dst != nil && r.Dst.String() == dstNet.String() {
+			return nil
+		}
+		used[r.Priority] = true
+	}
+	allRules, err := netlink.RuleList(netlinkFamily(dstNet.IP))
+	if err != nil {
+		return checkNetlinkError("list rules", err)
+	}
+	for _, r := range allRules {
+		used[r.Priority] = true
+	}
+
+	rule := netlink.NewRule()
+	rule.Family = netlinkFamily(dstNet.IP)
+	rule.Dst = dstNet
+	rule.Table = table
+	for prio := RulePriorityMin; prio <= RulePriorityMax; prio++ {
+		if !used[prio] {
+			rule.Priority = prio
+			break
+		}
+	}
+	if rule.Priority < 0 {
+		return errors.New("No free rule priority left for sasectl")
+	}
+	err = checkNetlinkError("add rule to "+dst, netlink.RuleAdd(rule))
+	if err == nil {
+		AuditChange("rule add " + rule.String())
+	}
+	return err
+}
+
+// ListPolicyRules lists rules installed by sasectl which lookup table.
+func ListPolicyRules(table int) ([]netlink.Rule, error) {
+	var rules []netlink.Rule
+	for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		familyRules, err := netlink.RuleList(family)
+		if err != nil {
+			return nil, checkNetlinkError("list rules", err)
+		}
+		for _, r := range familyRules {
+			if r.Table == table && r.Priority >= RulePriorityMin && r.Priority <= RulePriorityMax {
+				rules = append(rules, r)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// DelPolicyRule removes the rule "to <dst> lookup <table>" installed by sasectl.
+func DelPolicyRule(dst string, table int) error {
+	_, dstNet, err := net.ParseCIDR(dst)
+	if err != nil {
+		return errors.New("Invalid rule destination " + dst + ": " + err.Error())
+	}
+	rules, err := ListPolicyRules(table)
+	if err != nil {
+		return err
+	}
+	for i := range rules {
+		if rules[i].Dst != nil && rules[i].Dst.String() == dstNet.String() {
+			err = netlink.RuleDel(&rules[i])
+			if err != nil && !errors.Is(err, syscall.ENOENT) {
+				return checkNetlinkError("delete rule "+rules[i].String(), err)
+			}
+			AuditChange("rule delete " + rules[i].String())
+		}
+	}
+	return nil
+}
+
+// DelPolicyRules removes rules installed by sasectl which lookup table.
+func DelPolicyRules(table int) error {
+	rules, err := ListPolicyRules(table)
+	if err != nil {
+		return err
+	}
+	for i := range rules {
+		err = netlink.RuleDel(&rules[i])
+		if err != nil && !errors.Is(err, syscall.ENOENT) {
+			return checkNetlinkError("delete rule "+rules[i].String(), err)
+		}
+		AuditChange("rule delete " + rules[i].String())
+	}
+	return nil
+}
+
+// SetDefaultRoute installs or replaces default route via gw dev ifName in table.
+func SetDefaultRoute(gw string, ifName string, table int) error {
+	gwIP := net.ParseIP(gw)
+	if gwIP == nil {
+		return errors.New("Invalid gateway address " + gw)
+	}
+	link, err := netlink.LinkByName(ifName)
+	if err != nil {
+		return errors.New("Failed to find interface " + ifName + ": " + err.Error())
+	}
+
+	dst := &net.IPNet{IP: net.IPv4zero, Mask: net.CIDRMask(0, 32)}
+	if gwIP.To4() == nil {
+		dst = &net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)}
+	}
+	route := &netlink.Route{
+		LinkIndex: link.Attrs().Index,
+		Dst:       dst,
+		Gw:        gwIP,
+		Table:     table,
+		Protocol:  RouteProtocolSasectl,
+	}
+	err = checkNetlinkError("replace default route in table", netlink.RouteReplace(route))
+	if err == nil {
+		AuditChange("route replace " + route.String())
+	}
+	return err
+}
+
+// ListPolicyRoutes lists routes installed by sasectl in table.
+func ListPolicyRoutes(table int) ([]netlink.Route, error) {
+	filter := &netlink.Route{Table: table, Protocol: RouteProtocolSasectl}
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_ALL, filter,
+		netlink.RT_FILTER_TABLE|netlink.RT_FILTER_PROTOCOL)
+	if err != nil {
+		return nil, checkNetlinkError("list routes", err)
+	}
+	return routes, nil
+}
+
+// FlushPolicyRoutes removes routes installed by sasectl in table.
+func FlushPolicyRoutes(table int) error {
+	routes, err := ListPolicyRoutes(table)
+	if err != nil {
+		return err
+	}
+	for i := range routes {
+		err = netlink.RouteDel(&routes[i])
+		if err != nil && !errors.Is(err, syscall.ESRCH) {
+			return checkNetlinkError("delete route "+routes[i].String(), err)
+		}
+		AuditChange("route delete " + routes[i].String())
+	}
+	return nil
+}
+
+// DelLegacyPolicyRouting removes the rules and default route of table
+// OverlayRouteTable which sasectl added with "ip rule" and "ip route" before it
+// used netlink. They have kernel assigned priorities and the boot protocol, so
+// they are neither managed as sasectl's own nor left to other users of the table.
+func DelLegacyPolicyRouting() error {
+	routes, rules, err := listAllRoutesRules()
+	if err != nil {
+		return err
+	}
+	for i := range rules {
+		r := &rules[i]
+		// "ip rule add to <dst> lookup <table>" sets no other selector.
+		if r.Table != OverlayRouteTable || r.Dst == nil || r.Src != nil || r.IifName != "" || r.OifName != "" ||
+			r.Mark > 0 || (r.Priority >= RulePriorityMin && r.Priority <= RulePriorityMax) {
+			continue
+		}
+		err = netlink.RuleDel(r)
+		if err != nil && !errors.Is(err, syscall.ENOENT) {
+			return checkNetlinkError("delete rule "+r.String(), err)
+		}
+		log.Println("Removed policy rule " + r.String() + " of earlier sasectl.")
+		AuditChange("rule delete " + r.String())
+	}
+	for i := range routes {
+		r := &routes[i]
+		isDefault := r.Dst == nil || r.Dst.IP.IsUnspecified()
+		if r.Table != OverlayRouteTable || r.Protocol != syscall.RTPROT_BOOT || !isDefault {
+			continue
+		}
+		err = netlink.RouteDel(r)
+		if err != nil && !errors.Is(err, syscall.ESRCH) {
+			return checkNetlinkError("delete route "+r.String(), err)
+		}
+		log.Println("Removed route " + r.String() + " of earlier sasectl.")
+		AuditChange("route delete " + r.String())
+	}
+	return nil
+}
+
+// CheckRouteTable fails if table is reserved or used by routes or rules not installed by sasectl.
+func CheckRouteTable(table int) error {
+	sTable := strconv.Itoa(table)
+	if table < RouteTableMin || table > RouteTableMax {
+		return errors.New("Routing table " + sTable + " is reserved, use " +
+			strconv.Itoa(RouteTableMin) + "-" + strconv.Itoa(RouteTableMax))
+	}
+	routes, rules, err := listAllRoutesRules()
+	if err != nil {
+		return err
+	}
+	for _, r := range routes {
+		if r.Table == table && r.Protocol != RouteProtocolSasectl {
+			return errors.New("Routing table " + sTable + " is in use by route " + r.String())
+		}
+	}
+	for _, r := range rules {
+		if r.Table == table && (r.Priority < RulePriorityMin || r.Priority > RulePriorityMax) {
+			return errors.New("Routing table " + sTable + " is in use by rule " + r.String())
+		}
+	}
+	return nil
+}
+
+// AllocRouteTable returns the first table from OverlayRouteTable on which no
+// route or rule exists.
+func AllocRouteTable() (int, error) {
+	routes, rules, err := listAllRoutesRules()
+	if err != nil {
+		return 0, err
+	}
+	used := make(map[int]bool)
+	for _, r := range routes {
+		used[r.Table] = true
+	}
+	for _, r := range rules {
+		used[r.Table] = true
+	}
+	for table := OverlayRouteTable; table <= RouteTableMax; table++ {
+		if !used[table] {
+			return table, nil
+		}
+	}
+	for table := RouteTableMin; table < OverlayRouteTable; table++ {
+		if !used[table] {
+			return table, nil
+		}
+	}
+	return 0, errors.New("No free routing table left")
+}
+
+func listAllRoutesRules() ([]netlink.Route, []netlink.Rule, error) {
+	filter := &netlink.Route{Table: syscall.RT_TABLE_UNSPEC}
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_ALL, filter, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		return nil, nil, checkNetlinkError("list routes", err)
+	}
+	var rules []netlink.Rule
+	for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		familyRules, err := netlink.RuleList(family)
+		if err != nil {
+			return nil, nil, checkNetlinkError("list rules", err)
+		}
+		rules = append(rules, familyRules...)
+	}
+	return routes, rules, nil
+}
+
+// HostCIDR returns the /32 or /128 network of a single address.
+func HostCIDR(ip string) (string, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return "", errors.New("Invalid IP address " + ip)
+	}
+	if addr.To4() != nil {
+		return addr.String() + "/32", nil
+	}
+	return addr.String() + "/128", nil
+}
+
+// GetIPIfName returns the calico interface used to reach IPaddr.
+func GetIPIfName(IPaddr string) string {
+	ip := net.ParseIP(IPaddr)
+	if ip == nil {
+		return ""
+	}
+	routes, err := netlink.RouteGet(ip)
+	if err != nil {
+		return ""
+	}
+	for _, r := range routes {
+		link, err := netlink.LinkByIndex(r.LinkIndex)
+		if err != nil {
+			continue
+		}
+		if strings.HasPrefix(link.Attrs().Name, "cali") {
+			return link.Attrs().Name
+		}
+	}
+	return ""
+}
+
+func netlinkFamily(ip net.IP) int {
+	if ip.To4() != nil {
+		return netlink.FAMILY_V4
+	}
+	return netlink.FAMILY_V6
+}
+
+func checkNetlinkError(action string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, syscall.EPERM) {
+		return errors.New("Failed to " + action + ": operation not permitted, run sasectl as root or with CAP_NET_ADMIN")
+	}
+	return errors.New("Failed to " + action + ": " + err.Error())
+}
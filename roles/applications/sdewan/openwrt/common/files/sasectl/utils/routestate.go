@@ -0,0 +1,336 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	RouteIntentFP   = "/etc/sasectl/routes.yaml"
+	RouteUnitName   = "sasectl-routes.service"
+	RouteUnitFP     = "/etc/systemd/system/" + RouteUnitName
+	RouteGatewayPod = "safe"
+	// RouteApplyWait bounds how long the unit retries at boot, e.g. until
+	// the provider device is up.
+	RouteApplyWait       = "10m"
+	RouteApplyRetryDelay = 15 * time.Second
+)
+
+// RouteIntent is the host policy routing that sasectl keeps across reboots.
+// ProviderCIDR and Gateway are IPv4, ProviderCIDR6 and Gateway6 are set for an
+// IPv6 provider network.
+type RouteIntent struct {
+	Table         int      `yaml:"table"`
+	ProviderCIDR  string   `yaml:"providerCIDR"`
+	ProviderCIDR6 string   `yaml:"providerCIDR6,omitempty"`
+	RuleDsts      []string `yaml:"ruleDestinations"`
+	Gateway       string   `yaml:"gateway"`
+	Gateway6      string   `yaml:"gateway6,omitempty"`
+	Device        string   `yaml:"device"`
+}
+
+const routeUnitTemplate = `# Generated by sasectl, removed by "sasectl reset".
+[Unit]
+Description=Restore policy routing of Smart-Edge Open SASE EK overlay
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=oneshot
+RemainAfterExit=yes
+ExecStart=%s routes apply --wait ` + RouteApplyWait + `
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// ProviderCIDRs returns the provider networks of intent which are set.
+func (intent *RouteIntent) ProviderCIDRs() []string {
+	var cidrs []string
+	for _, cidr := range []string{intent.ProviderCIDR, intent.ProviderCIDR6} {
+		if cidr != "" {
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	return cidrs
+}
+
+// gateway returns the gateway of intent for rules of a family.
+func (intent *RouteIntent) gateway(ipv6 bool) string {
+	if ipv6 {
+		return intent.Gateway6
+	}
+	return intent.Gateway
+}
+
+// ruleFamilies returns whether dsts have IPv4 and IPv6 destinations.
+func ruleFamilies(dsts []string) (bool, bool) {
+	var v4, v6 bool
+	for _, dst := range dsts {
+		_, dstNet, err := net.ParseCIDR(dst)
+		if err != nil {
+			continue
+		}
+		if dstNet.IP.To4() != nil {
+			v4 = true
+		} else {
+			v6 = true
+		}
+	}
+	return v4, v6
+}
+
+func LoadRouteIntent(fp string) (*RouteIntent, error) {
+	var intent RouteIntent
+	data, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return nil, err
+	}
+	err = yaml.Unmarshal(data, &intent)
+	if err != nil {
+		return nil, err
+	}
+	return &intent, nil
+}
+
+func SaveRouteIntent(fp string, intent *RouteIntent) error {
+	data, err := yaml.Marshal(intent)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(fp), 0755)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(fp, data, 0644)
+	if err == nil {
+		AuditChange("file " + fp)
+	}
+	return err
+}
+
+// ApplyRouteIntent installs rules and default routes of intent, one for each
+// family of rules, and removes rules of sasectl which are no longer in intent.
+// Gateways are refreshed from the CNF pod when the cluster is reachable, since
+// the pod IPs may change after a reboot.
+func ApplyRouteIntent(intent *RouteIntent) error {
+	wanted := make(map[string]bool)
+	for _, dst := range intent.RuleDsts {
+		err := AddPolicyRule(dst, intent.Table)
+		if err != nil {
+			return err
+		}
+		_, dstNet, _ := net.ParseCIDR(dst)
+		wanted[dstNet.String()] = true
+	}
+	rules, err := ListPolicyRules(intent.Table)
+	if err != nil {
+		return err
+	}
+	for _, r := range rules {
+		if r.Dst != nil && !wanted[r.Dst.String()] {
+			err = DelPolicyRule(r.Dst.String(), intent.Table)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	refreshed := *intent
+	podIPs, err := GetPodIPs(RouteGatewayPod)
+	if err == nil {
+		for _, podIP := range podIPs {
+			gateway := &refreshed.Gateway
+			if IsIPv6(podIP) {
+				gateway = &refreshed.Gateway6
+			}
+			if podIP != *gateway {
+				if *gateway != "" {
+					log.Printf("CNF address changed from %s to %s.", *gateway, podIP)
+				}
+				*gateway = podIP
+				refreshed.Device = GetIPIfName(podIP)
+			}
+		}
+	}
+	if refreshed.Device == "" {
+		return errors.New("Interface to CNF pod " + RouteGatewayPod + " is not ready")
+	}
+
+	v4, v6 := ruleFamilies(intent.RuleDsts)
+	for _, family := range []struct {
+		ipv6   bool
+		needed bool
+	}{{false, v4}, {true, v6}} {
+		if !family.needed {
+			continue
+		}
+		gateway := refreshed.gateway(family.ipv6)
+		if gateway == "" {
+			name := "IPv4"
+			if family.ipv6 {
+				name = "IPv6"
+			}
+			return errors.New("CNF has no " + name + " address to route " + name + " rules of table " + strconv.Itoa(intent.Table))
+		}
+		err = SetDefaultRoute(gateway, refreshed.Device, intent.Table)
+		if err != nil {
+			return err
+		}
+	}
+	if refreshed.Gateway != intent.Gateway || refreshed.Gateway6 != intent.Gateway6 || refreshed.Device != intent.Device {
+		intent.Gateway, intent.Gateway6, intent.Device = refreshed.Gateway, refreshed.Gateway6, refreshed.Device
+		return SaveRouteIntent(RouteIntentFP, intent)
+	}
+	return nil
+}
+
+// VerifyRouteIntent compares live rules and routes with intent, and returns the differences.
+func VerifyRouteIntent(intent *RouteIntent) ([]string, error) {
+	var diffs []string
+	table := strconv.Itoa(intent.Table)
+
+	rules, err := ListPolicyRules(intent.Table)
+	if err != nil {
+		return nil, err
+	}
+	liveDsts := make(map[string]bool)
+	for _, r := range rules {
+		if r.Dst != nil {
+			liveDsts[r.Dst.String()] = true
+		}
+	}
+	for _, dst := range intent.RuleDsts {
+		_, dstNet, err := net.ParseCIDR(dst)
+		if err != nil {
+			return nil, err
+		}
+		if !liveDsts[dstNet.String()] {
+			diffs = append(diffs, "missing rule: to "+dst+" lookup "+table)
+		}
+		delete(liveDsts, dstNet.String())
+	}
+	for dst := range liveDsts {
+		diffs = append(diffs, "unexpected rule: to "+dst+" lookup "+table)
+	}
+
+	routes, err := ListPolicyRoutes(intent.Table)
+	if err != nil {
+		return nil, err
+	}
+	v4, v6 := ruleFamilies(intent.RuleDsts)
+	for _, family := range []struct {
+		ipv6   bool
+		needed bool
+	}{{false, v4}, {true, v6}} {
+		if !family.needed {
+			continue
+		}
+		gateway := intent.gateway(family.ipv6)
+		found := false
+		for _, r := range routes {
+			if r.Dst != nil {
+				if ones, _ := r.Dst.Mask.Size(); ones != 0 {
+					continue
+				}
+			}
+			if (r.Gw.To4() == nil) != family.ipv6 {
+				continue
+			}
+			found = true
+			devName := ""
+			link, err := netlink.LinkByIndex(r.LinkIndex)
+			if err == nil {
+				devName = link.Attrs().Name
+			}
+			if !r.Gw.Equal(net.ParseIP(gateway)) || devName != intent.Device {
+				diffs = append(diffs, "default route in table "+table+" is via "+r.Gw.String()+" dev "+devName+
+					", expected via "+gateway+" dev "+intent.Device)
+			}
+		}
+		if !found {
+			diffs = append(diffs, "missing route: default via "+gateway+" dev "+intent.Device+" table "+table)
+		}
+	}
+
+	output, err := exec.Command("systemctl", "is-enabled", RouteUnitName).CombinedOutput()
+	if err != nil {
+		diffs = append(diffs, RouteUnitName+" is not enabled: "+string(output))
+	}
+	return diffs, nil
+}
+
+// InstallRouteUnit installs and enables the systemd unit restoring routes at boot.
+func InstallRouteUnit() error {
+	sasectlPath, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	unit := []byte(fmt.Sprintf(routeUnitTemplate, sasectlPath))
+	err = ioutil.WriteFile(RouteUnitFP, unit, 0644)
+	if err != nil {
+		return err
+	}
+	AuditChange("file " + RouteUnitFP)
+
+	cmdList := []CmdInfo{
+		{CmdName: "systemctl", CmdArgs: []string{"daemon-reload"}},
+		{CmdName: "systemctl", CmdArgs: []string{"enable", RouteUnitName}},
+	}
+	return runCmdList(cmdList)
+}
+
+// RemoveRouteUnit disables and removes the systemd unit and the persisted intent.
+func RemoveRouteUnit() error {
+	if _, err := os.Stat(RouteUnitFP); err == nil {
+		err = runCmdList([]CmdInfo{{CmdName: "systemctl", CmdArgs: []string{"disable", RouteUnitName}}})
+		if err != nil {
+			return err
+		}
+		err = os.Remove(RouteUnitFP)
+		if err != nil {
+			return err
+		}
+		AuditChange("file delete " + RouteUnitFP)
+		err = runCmdList([]CmdInfo{{CmdName: "systemctl", CmdArgs: []string{"daemon-reload"}}})
+		if err != nil {
+			return err
+		}
+	}
+	err := os.Remove(RouteIntentFP)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		AuditChange("file delete " + RouteIntentFP)
+	}
+	return nil
+}
+
+func runCmdList(cmdList []CmdInfo) error {
+	for _, item := range cmdList {
+		cmd := exec.Command(item.CmdName, item.CmdArgs...)
+		cmd.Dir = item.CmdDir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			log.Println(string(output))
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	sccServerUrl   string
+	sccPortForward *exec.Cmd
+)
+
+// GetSCCServerUrl returns the base url of SCC REST API, e.g. "http://10.96.0.20:9015".
+// An explicit endpoint always wins. Otherwise the SCC service is looked up, and
+// a local port-forward is started when the service is not reachable from here.
+// The scheme follows the rest context set by SetRestContext.
+func GetSCCServerUrl(endpoint string) (string, error) {
+	if sccServerUrl != "" {
+		return sccServerUrl, nil
+	}
+
+	if endpoint != "" {
+		if !strings.Contains(endpoint, "://") {
+			endpoint = RestScheme() + "://" + endpoint
+		}
+		sccServerUrl = strings.TrimSuffix(endpoint, "/")
+		return sccServerUrl, nil
+	}
+
+	clusterIP, port, err := getSCCService()
+	if err != nil {
+		return "", err
+	}
+
+	addr := net.JoinHostPort(clusterIP, port)
+	conn, err := net.DialTimeout("tcp", addr, SCCDialTimeout)
+	if err == nil {
+		conn.Close()
+		sccServerUrl = RestScheme() + "://" + addr
+		return sccServerUrl, nil
+	}
+
+	log.Printf("SCC service %s is not reachable, falling back to port-forward.", addr)
+	localAddr, err := startSCCPortForward(port)
+	if err != nil {
+		return "", err
+	}
+	sccServerUrl = RestScheme() + "://" + localAddr
+	return sccServerUrl, nil
+}
+
+// StopSCCPortForward terminates the port-forward started by GetSCCServerUrl, if any,
+// so that the next call resolves the endpoint again.
+func StopSCCPortForward() {
+	sccServerUrl = ""
+	if sccPortForward == nil || sccPortForward.Process == nil {
+		return
+	}
+	syscall.Kill(-sccPortForward.Process.Pid, syscall.SIGKILL)
+	sccPortForward.Wait()
+	sccPortForward = nil
+}
+
+// WaitSCCReady polls SCC until its REST API answers or timeout expires.
+func WaitSCCReady(endpoint string, timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		serverUrl, err := GetSCCServerUrl(endpoint)
+		if err == nil {
+			_, _, err = callRestOnce("GET", serverUrl+"/scc/v1/"+OverlayCollection, "")
+		}
+		if err == nil {
+			log.Println("SCC is ready.")
+			return serverUrl, nil
+		}
+		StopSCCPortForward()
+		if time.Now().After(deadline) {
+			return "", errors.New("SCC is not ready after " + timeout.String() + ": " + err.Error())
+		}
+		log.Printf("Waiting for SCC to be ready: %s", err.Error())
+		time.Sleep(SCCReadyInterval)
+	}
+}
+
+func getSCCService() (string, string, error) {
+	svcCmd := exec.Command("kubectl", "get", "service", SCCServiceName, "-n", NameSpaceName,
+		"-o", "jsonpath={.spec.clusterIP} {.spec.ports[0].port}")
+	output, err := svcCmd.CombinedOutput()
+	if err != nil {
+		log.Print(string(output))
+		return "", "", errors.New("Failed to find service " + SCCServiceName + " in namespace " + NameSpaceName)
+	}
+
+	fields := strings.Fields(string(output))
+	if len(fields) != 2 || fields[0] == "None" {
+		return "", "", errors.New("Service " + SCCServiceName + " has no cluster IP or port")
+	}
+	return fields[0], fields[1], nil
+}
+
+func startSCCPortForward(port string) (string, error) {
+	pfCmd := exec.Command("kubectl", "port-forward", "-n", NameSpaceName, "service/"+SCCServiceName, ":"+port)
+	// Run kubectl in its own process group so it can be killed with any child it
+	// starts, and have the kernel terminate it if sasectl exits without stopping it.
+	pfCmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Pdeathsig: syscall.SIGTERM}
+	stdout, err := pfCmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	err = pfCmd.Start()
+	if err != nil {
+		return "", err
+	}
+
+	// kubectl prints "Forwarding from 127.0.0.1:<local port> -> <port>" once ready.
+	ready := make(chan string, 1)
+	go func() {
+		reader := bufio.NewReader(stdout)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				close(ready)
+				return
+			}
+			fields := strings.Fields(line)
+			if len(fields) > 2 && fields[0] == "Forwarding" && fields[1] == "from" &&
+				!strings.HasPrefix(fields[2], "[") {
+				ready <- fields[2]
+				io.Copy(ioutil.Discard, reader)
+				return
+			}
+		}
+	}()
+
+	select {
+	case localAddr, ok := <-ready:
+		if !ok {
+			pfCmd.Wait()
+			return "", errors.New("Port-forward to service " + SCCServiceName + " exited unexpectedly")
+		}
+		sccPortForward = pfCmd
+		return localAddr, nil
+	case <-time.After(SCCPortForwardTimeout):
+		syscall.Kill(-pfCmd.Process.Pid, syscall.SIGKILL)
+		pfCmd.Wait()
+		return "", errors.New("Timeout waiting for port-forward to service " + SCCServiceName)
+	}
+}
@@ -5,11 +5,9 @@
 package utils
 
 import (
-	"bytes"
 	"errors"
 	"io/ioutil"
 	"log"
-	"net/http"
 	"os/exec"
 	"reflect"
 	"strings"
@@ -21,10 +19,29 @@ import (
 type CNFValue map[string]interface{}
 
 type SaseCtlConf struct {
-	ICNSdewanFilePath      string `yaml:"ICN-Sdewan-File-Path"`
-	ICNSdewanRole          string `yaml:"ICN-Sdewan-Role"`
-	ICNSdewanCNFChartName  string `yaml:"ICN-Sdewan-CNF-Chart"`
-	ICNSdewanCtrlChartName string `yaml:"ICN-Sdewan-Ctrl-Chart"`
+	ICNSdewanFilePath      string       `yaml:"ICN-Sdewan-File-Path"`
+	ICNSdewanRole          string       `yaml:"ICN-Sdewan-Role"`
+	ICNSdewanCNFChartName  string       `yaml:"ICN-Sdewan-CNF-Chart"`
+	ICNSdewanCtrlChartName string       `yaml:"ICN-Sdewan-Ctrl-Chart"`
+	SCCEndpoint            string       `yaml:"SCC-Endpoint,omitempty"`
+	RestTimeout            string       `yaml:"REST-Timeout,omitempty"`
+	RestRetries            *int         `yaml:"REST-Retries,omitempty"`
+	RouteTable             int          `yaml:"Route-Table,omitempty"`
+	CurrentContext         string       `yaml:"Current-Context,omitempty"`
+	Contexts               []SCCContext `yaml:"Contexts,omitempty"`
+	AuditLog               string       `yaml:"Audit-Log,omitempty"`
+}
+
+// SCCContext holds the connection settings of one SCC (overlay controller).
+type SCCContext struct {
+	Name               string `yaml:"name"`
+	SCCEndpoint        string `yaml:"scc-endpoint,omitempty"`
+	CAFile             string `yaml:"ca-file,omitempty"`
+	CertFile           string `yaml:"cert-file,omitempty"`
+	KeyFile            string `yaml:"key-file,omitempty"`
+	TLSServerName      string `yaml:"tls-server-name,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecure-skip-tls-verify,omitempty"`
+	TokenFile          string `yaml:"token-file,omitempty"`
 }
 
 type CmdInfo struct {
@@ -45,6 +62,12 @@ type ICNSdewanProposalObjectSpec struct {
 	DhGroup    string `json:"dhGroup"`
 }
 
+// ConnectionEndName returns the name of the hub or device of a connection
+// end. SCC names the end "<Type>.<name>", e.g. "Hub.pop1".
+func ConnectionEndName(e module.ConnectionEnd) string {
+	return strings.TrimPrefix(e.Name, e.Type+".")
+}
+
 type ICNSccDbConfig struct {
 	EtcdIP string `json:"etcd-ip"`
 	DBIP   string `json:"database-ip"`
@@ -94,33 +117,77 @@ func LoadSasectlConfig(fp string) (*SaseCtlConf, error) {
 	var c SaseCtlConf
 	yamlFile, err := ioutil.ReadFile(fp)
 	if err != nil {
-		log.Fatal(err)
+		Fatal(err)
 		return nil, err
 	}
 	err = yaml.Unmarshal(yamlFile, &c)
 	if err != nil {
-		log.Fatal(err)
+		Fatal(err)
 		return nil, err
 	}
 	return &c, nil
 }
 
+// GetContext returns the named context, or the current context if name is empty.
+// nil is returned when no context is configured at all.
+func (c *SaseCtlConf) GetContext(name string) (*SCCContext, error) {
+	if name == "" {
+		name = c.CurrentContext
+	}
+	if name == "" {
+		return nil, nil
+	}
+	for i := range c.Contexts {
+		if c.Contexts[i].Name == name {
+			return &c.Contexts[i], nil
+		}
+	}
+	return nil, errors.New("Context " + name + " not found in sasectl config")
+}
+
 func SetClusterRole(fp string, role string, conf *SaseCtlConf) bool {
 	conf.ICNSdewanRole = role
+	return saveSasectlConfig(fp, conf)
+}
+
+func SetCurrentContext(fp string, name string, conf *SaseCtlConf) bool {
+	conf.CurrentContext = name
+	return saveSasectlConfig(fp, conf)
+}
+
+func SetRouteTable(fp string, table int, conf *SaseCtlConf) bool {
+	conf.RouteTable = table
+	return saveSasectlConfig(fp, conf)
+}
+
+func saveSasectlConfig(fp string, conf *SaseCtlConf) bool {
 	d, err := yaml.Marshal(conf)
 	if err != nil {
-		log.Fatal(err)
+		Fatal(err)
 		return false
 	}
+	// The config is read by every command, secrets are kept in the files
+	// it refers to.
 	err = ioutil.WriteFile(fp, d, 0644)
 	if err != nil {
-		log.Fatal(err)
+		Fatal(err)
 		return false
 	}
+	AuditChange("file " + fp)
 	return true
 }
 
 func CheckPodIP(podName string) string {
+	podIP, err := GetPodIP(podName)
+	if err != nil {
+		Fatal(err)
+		return ""
+	}
+	return podIP
+}
+
+// GetPodIP is CheckPodIP returning the error instead of exiting.
+func GetPodIP(podName string) (string, error) {
 	listNameCmd := CmdInfo{
 		CmdName: "kubectl",
 		CmdArgs: []string{"get", "pod", "-n", "sdewan-system", "--no-headers", "-o", "custom-columns=Name:.metadata.name,IP:.status.podIP"},
@@ -129,64 +196,64 @@ func CheckPodIP(podName string) string {
 	cmd := exec.Command(listNameCmd.CmdName, listNameCmd.CmdArgs...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		log.Fatal(err)
-		return ""
+		return "", err
 	}
 	soutput := strings.Split(string(output), "\n")
 	for _, item := range soutput {
 		boutput := strings.Fields(item)
 		if len(boutput) > 1 && strings.Contains(boutput[0], podName) {
-			return boutput[1]
+			return boutput[1], nil
 		}
 	}
-	return ""
+	return "", nil
 }
 
-func CheckPodFullname(keyword string) string {
-	listNameCmd := CmdInfo{
-		CmdName: "kubectl",
-		CmdArgs: []string{"get", "pod", "-n", "sdewan-system", "--no-headers", "-o", "custom-columns=Name:.metadata.name"},
-	}
-
-	cmd := exec.Command(listNameCmd.CmdName, listNameCmd.CmdArgs...)
+// GetPodIPs returns the addresses of a pod in sdewan-system, an IPv4 and an
+// IPv6 one if the cluster is dual-stack.
+func GetPodIPs(podName string) ([]string, error) {
+	cmd := exec.Command("kubectl", "get", "pod", "-n", "sdewan-system", "--no-headers", "-o", "custom-columns=Name:.metadata.name,IPs:.status.podIPs[*].ip")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		log.Fatal(err)
-		return ""
+		return nil, err
 	}
-	soutput := strings.Split(string(output), "\n")
-	for _, item := range soutput {
+	for _, item := range strings.Split(string(output), "\n") {
 		boutput := strings.Fields(item)
-		if len(boutput) > 0 && strings.Contains(boutput[0], keyword) {
-			return boutput[0]
+		if len(boutput) > 1 && strings.Contains(boutput[0], podName) && boutput[1] != "<none>" {
+			return strings.Split(boutput[1], ","), nil
 		}
 	}
-	return ""
+	return nil, nil
 }
 
-func GetIPIfName(IPaddr string) string {
-	cmd := exec.Command("ip", "route", "get", IPaddr)
+func CheckPodFullname(keyword string) string {
+	podName, err := FindPodFullname(keyword)
+	if err != nil {
+		Fatal(err)
+	}
+	return podName
+}
+
+// FindPodFullname returns the name of the first pod in sdewan-system which
+// contains keyword, or "" if there is none.
+func FindPodFullname(keyword string) (string, error) {
+	listNameCmd := CmdInfo{
+		CmdName: "kubectl",
+		CmdArgs: []string{"get", "pod", "-n", "sdewan-system", "--no-headers", "-o", "custom-columns=Name:.metadata.name"},
+	}
+
+	cmd := exec.Command(listNameCmd.CmdName, listNameCmd.CmdArgs...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		log.Fatal(err)
-		return ""
+		return "", errors.New("Failed to list pods: " + err.Error())
 	}
 	soutput := strings.Split(string(output), "\n")
 	for _, item := range soutput {
 		boutput := strings.Fields(item)
-		if len(boutput) > 1 {
-			for i, v := range boutput {
-				if v == "dev" && i+1 < len(boutput) {
-					if strings.HasPrefix(boutput[i+1], "cali") {
-						return boutput[i+1]
-					} else {
-						continue
-					}
-				}
-			}
+		if len(boutput) > 0 && strings.Contains(boutput[0], keyword) {
+			return boutput[0], nil
 		}
 	}
-	return ""
+	return "", nil
 }
 
 func LoadCNFValueFile(cnfValueFp string) CNFValue {
@@ -196,8 +263,8 @@ func LoadCNFValueFile(cnfValueFp string) CNFValue {
 
 	data, err := ioutil.ReadFile(cnfValueFp)
 	if err != nil {
-		log.Fatal("Failed to open cnf value file.")
-		log.Fatal(err)
+		Fatal("Failed to open cnf value file.")
+		Fatal(err)
 	}
 
 	yaml.Unmarshal(data, &cnfValue)
@@ -220,33 +287,52 @@ func LoadCNFValueFile(cnfValueFp string) CNFValue {
 				existedData = append(existedData, &thisData)
 			}
 		default:
-			log.Fatal("Error type in nfn field.")
+			Fatal("Error type in nfn field.")
 		}
 	} else {
-		log.Fatal("Illegal cnf value file.")
+		Fatal("Illegal cnf value file.")
 		return nil
 	}
 	cnfValue["nfn"] = existedData
 	return cnfValue
 }
 
+// Nfn returns the networks of CNF in cnfValue.
+func (cnfValue CNFValue) Nfn() ([]*ICNNfnConfig, error) {
+	nfn, ok := cnfValue["nfn"].([]*ICNNfnConfig)
+	if !ok {
+		return nil, errors.New("No valid nfn networks in CNF values")
+	}
+	return nfn, nil
+}
+
 func UpdateCNFValueFile(cnfValueFp string, cnfValue CNFValue) {
+	outData, err := MarshalCNFValue(cnfValue)
+	if err != nil {
+		Fatal("Failed to export cnf value file.")
+	}
+
+	err = ioutil.WriteFile(cnfValueFp, outData, 0666)
+	if err != nil {
+		log.Print(err.Error())
+		Fatal("Failed to export CNF value file.")
+	}
+	AuditChange("file " + cnfValueFp)
+}
+
+// MarshalCNFValue renders cnfValue as content of values.yaml of the CNF chart.
+func MarshalCNFValue(cnfValue CNFValue) ([]byte, error) {
 	var outData []byte
 
 	cnfValueData, err := yaml.Marshal(cnfValue)
 	if err != nil {
-		log.Fatal("Failed to export cnf value file.")
+		return nil, err
 	}
 
 	outData = append(outData, []byte(CNFValueCopyright)...)
 	outData = append(outData, '\n')
 	outData = append(outData, cnfValueData...)
-
-	err = ioutil.WriteFile(cnfValueFp, outData, 0666)
-	if err != nil {
-		log.Print(err.Error())
-		log.Fatal("Failed to export CNF value file.")
-	}
+	return outData, nil
 }
 
 func ResetCNFValueNFN(cnfValue CNFValue) {
@@ -271,6 +357,21 @@ func ResetCNFValueNFN(cnfValue CNFValue) {
 }
 
 func GenerateCMYaml(cmFp string, clusterRole string) {
+	outData, err := RenderCMYaml(clusterRole)
+	if err != nil {
+		Fatal("Failed to parse cm yaml data for CNF")
+	}
+
+	err = ioutil.WriteFile(cmFp, outData, 0664)
+	if err != nil {
+		log.Print(err.Error())
+		Fatal("Failed to gemerate CNF config map template.")
+	}
+	AuditChange("file " + cmFp)
+}
+
+// RenderCMYaml renders the config map template of CNF entrypoint for clusterRole.
+func RenderCMYaml(clusterRole string) ([]byte, error) {
 
 	cmYamlData := ICNCnfcmYaml{
 		Kind:       "ConfigMap",
@@ -291,48 +392,11 @@ func GenerateCMYaml(cmFp string, clusterRole string) {
 
 	cmYaml, err := yaml.Marshal(cmYamlData)
 	if err != nil {
-		log.Fatal("Failed to parse cm yaml data for CNF")
+		return nil, err
 	}
 
 	outData := []byte(CNFCMCopyright)
 	outData = append(outData, '\n')
 	outData = append(outData, cmYaml...)
-
-	err = ioutil.WriteFile(cmFp, outData, 0664)
-	if err != nil {
-		log.Print(err.Error())
-		log.Fatal("Failed to gemerate CNF config map template.")
-	}
-}
-
-func CallRest(method string, url string, request string) (string, error) {
-	log.Printf("%s    %s    %s\n", method, url, request)
-	client := &http.Client{}
-	req_body := bytes.NewBuffer([]byte(request))
-	req, err := http.NewRequest(method, url, req_body)
-	if err != nil {
-		log.Print("Failed to create request to server.")
-		log.Print(err.Error())
-	}
-
-	req.Header.Set("Cache-Control", "no-cache")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-
-	if err != nil {
-		log.Print("Failed to parse returned body content.")
-		log.Print(err.Error())
-	}
-
-	if resp.StatusCode >= 400 {
-		return "", errors.New(string(body))
-	}
-
-	return string(body), nil
+	return outData, nil
 }
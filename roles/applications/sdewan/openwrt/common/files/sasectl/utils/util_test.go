@@ -0,0 +1,60 @@
+/**
+# SPDX-License-Identifier: Apache-2.0
+# Copyright (c) 2022 Intel Corporation
+**/
+package utils
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/akraino-edge-stack/icn-sdwan/central-controller/src/scc/pkg/module"
+)
+
+// A connection as returned by GET .../hubs/<hub>/connections of SCC.
+const sccConnectionJSON = `[{
+	"metadata": {"name": "Hub.pop1-Device.edge-1", "description": "", "userData1": "", "userData2": ""},
+	"information": {
+		"end1": {"name": "Hub.pop1", "type": "Hub", "ip": "10.10.70.39"},
+		"end2": {"name": "Device.edge-1", "type": "Device", "ip": "192.169.0.5"},
+		"state": "Deployed",
+		"message": ""
+	}
+}]`
+
+func TestDecodeSCCConnection(t *testing.T) {
+	var cons []module.ConnectionObject
+	err := json.Unmarshal([]byte(sccConnectionJSON), &cons)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cons) != 1 {
+		t.Fatalf("got %d connections, want 1", len(cons))
+	}
+	c := cons[0]
+	if c.Metadata.Name != "Hub.pop1-Device.edge-1" {
+		t.Errorf("name = %q", c.Metadata.Name)
+	}
+	if c.Info.State != Resource_Status_Deployed {
+		t.Errorf("state = %q, want %q", c.Info.State, Resource_Status_Deployed)
+	}
+
+	ends := []struct {
+		end      module.ConnectionEnd
+		typ      string
+		name     string
+		objName  string
+		endpoint string
+	}{
+		{c.Info.End1, "Hub", "Hub.pop1", "pop1", "10.10.70.39"},
+		{c.Info.End2, "Device", "Device.edge-1", "edge-1", "192.169.0.5"},
+	}
+	for _, e := range ends {
+		if e.end.Type != e.typ || e.end.Name != e.name || e.end.IP != e.endpoint {
+			t.Errorf("end = %+v, want type %q name %q ip %q", e.end, e.typ, e.name, e.endpoint)
+		}
+		if got := ConnectionEndName(e.end); got != e.objName {
+			t.Errorf("ConnectionEndName() of %q = %q, want %q", e.end.Name, got, e.objName)
+		}
+	}
+}